@@ -2,27 +2,32 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"brutus/internal/mqttreceiver/auth"
 	"brutus/internal/mqttreceiver/config"
+	"brutus/internal/mqttreceiver/exporter"
 	"brutus/internal/mqttreceiver/grpc"
+	"brutus/internal/mqttreceiver/ingest"
 	"brutus/internal/mqttreceiver/logger"
 	"brutus/internal/mqttreceiver/metrics"
 	"brutus/internal/mqttreceiver/mqtt"
+	"brutus/internal/mqttreceiver/sink"
 	"brutus/internal/mqttreceiver/storage"
+	"brutus/internal/mqttreceiver/tlsutil"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Структура сообщений в приниающем канале с брокера
-type IngestMessage struct {
-	Device    string
-	Parameter string
-	Value     string
-}
-
 func main() {
 
 	// Загрузка конфигурации
@@ -34,7 +39,7 @@ func main() {
 	logger.Init()
 
 	// Инициализация БД
-	db, err := storage.Initialized(cfg.DBFile)
+	db, err := storage.Open(cfg.DBDriver, cfg.DBDSN, cfg.DBMaxOpenConns)
 	if err != nil {
 		logger.Log.Fatal().
 			Str("component", "main").
@@ -42,6 +47,10 @@ func main() {
 			Msg("Database init failed")
 	}
 
+	db.SetHistoryRetention(cfg.HistoryRetentionDays)
+	db.SetRetentionRules(cfg.RetentionRules)
+	db.SetRecordingRules(cfg.RecordingRules)
+
 	// Запукаем горутину для очистки старых записей в истории значений
 	go func() {
 		ticker := time.NewTicker(24 * time.Hour)
@@ -54,68 +63,188 @@ func main() {
 			} else {
 				logger.Log.Info().Str("component", "main").Msg("Old history cleaned successfully")
 			}
+			if err := db.CleanOldRollups(); err != nil {
+				logger.Log.Error().Str("component", "main").Err(err).Msg("Failed to clean old rollups")
+			}
+		}
+	}()
+
+	// Фоновая агрегация истории в таблицы 1m/1h/1d
+	db.SetRollupRetention(cfg.RollupRetentionDays)
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.RollupIntervalSec) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := db.RunRollups(time.Now()); err != nil {
+				logger.Log.Error().Str("component", "main").Err(err).Msg("Failed to build history rollups")
+			}
 		}
 	}()
 
-	var mqttClient *mqtt.Client
-	grpcSrv := grpc.NewServer(db, mqttClient)
+	// Аутентификация клиентов gRPC
+	var authenticator *auth.Authenticator
+	if cfg.AuthEnabled {
+		authenticator, err = auth.NewAuthenticator(cfg.AuthRoles, cfg.AuthAPIKeys, cfg.AuthCertIdentities, cfg.AuthJWT)
+		if err != nil {
+			logger.Log.Fatal().Str("component", "main").Err(err).Msg("Auth init failed")
+		}
+		logger.Log.Info().Str("component", "main").Msg("gRPC authentication enabled")
+	}
+
+	// TLS gRPC; при проверке клиентских сертификатов их CN доступен авторизации
+	var grpcTLS *tls.Config
+	if cfg.GRPCTLSCertFile != "" {
+		reloader, err := tlsutil.NewReloader(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile, cfg.GRPCTLSClientCAFile)
+		if err != nil {
+			logger.Log.Fatal().Str("component", "main").Err(err).Msg("gRPC TLS init failed")
+		}
+		if grpcTLS, err = reloader.ServerConfig(cfg.GRPCTLSClientAuth); err != nil {
+			logger.Log.Fatal().Str("component", "main").Err(err).Msg("gRPC TLS init failed")
+		}
+	}
+
+	// Команды направляются брокеру, к которому подключено устройство
+	mqttRouter := mqtt.NewRouter()
+	grpcSrv := grpc.NewServer(db, mqttRouter, grpc.Options{
+		ReplayBuffer:        cfg.GRPCReplayBufferSize,
+		SubscriberBuffer:    cfg.GRPCSubscriberBuffer,
+		MaxSubscriberBuffer: cfg.GRPCSubscriberMax,
+		SlowConsumerPolicy:  cfg.GRPCSlowConsumer,
+		Auth:                authenticator,
+		TLS:                 grpcTLS,
+	})
+
+	// Очередь между MQTT и воркерами: в памяти или с журналом на диске
+	var ingestQueue ingest.Queue
+	if cfg.IngestQueueDir != "" {
+		ingestQueue, err = ingest.OpenDiskQueue(cfg.IngestQueueDir, cfg.IngestQueueMaxBytes, cfg.IngestQueueOverflow)
+		if err != nil {
+			logger.Log.Fatal().Str("component", "main").Err(err).Msg("Disk ingest queue init failed")
+		}
+	} else {
+		ingestQueue = ingest.NewMemoryQueue(cfg.MQTTIngestQueueSize, cfg.IngestQueueOverflow)
+	}
 
-	// Объявляем канал с настраиваемым размером
-	ingestQueue := make(chan IngestMessage, cfg.MQTTIngestQueueSize)
+	// Внешние приемники значений
+	var sinks []sink.Sink
+	if cfg.InfluxURL != "" {
+		sinks = append(sinks, sink.NewInfluxSink(sink.InfluxConfig{
+			URL:           cfg.InfluxURL,
+			Token:         cfg.InfluxToken,
+			Measurement:   cfg.InfluxMeasurement,
+			BatchSize:     cfg.InfluxBatchSize,
+			FlushInterval: time.Duration(cfg.InfluxFlushIntervalMs) * time.Millisecond,
+			SpoolFile:     cfg.InfluxSpoolFile,
+			SpoolMaxBytes: cfg.InfluxSpoolMaxBytes,
+		}))
+		logger.Log.Info().Str("component", "main").Str("url", cfg.InfluxURL).Msg("InfluxDB sink enabled")
+	}
 
-	// Условие для Воркеров, чтобы обрабатывать сообщения
+	// Воркеры пишут сообщения пачками: по размеру или по истечении интервала
+	broadcast := func(batch []ingest.Message) {
+		for _, msg := range batch {
+			grpcSrv.BroadcastValue(msg.Device, msg.Parameter, msg.Value, msg.Timestamp.UnixMilli())
+			for _, s := range sinks {
+				s.Write(msg.Device, msg.Parameter, msg.Value, msg.Timestamp)
+			}
+		}
+	}
+	var workers sync.WaitGroup
 	for i := 0; i < cfg.WorkerCount; i++ {
 		// Отдельная горутина для каждого Воркера
+		batcher := ingest.NewBatcher(
+			db,
+			ingestQueue,
+			cfg.IngestBatchSize,
+			time.Duration(cfg.IngestBatchIntervalMs)*time.Millisecond,
+			broadcast,
+		)
+		workers.Add(1)
 		go func() {
-			// Обрабатываем сообщения сообщение
-			for msg := range ingestQueue {
-				metrics.IngestQueueLength.Set(float64(len(ingestQueue)))
-				start := time.Now()
-
-				if err := db.SaveValue(msg.Device, msg.Parameter, msg.Value); err != nil {
-					logger.Log.Error().
-						Str("component", "ingestWorker").
-						Err(err).
-						Msg("Failed to save value")
-					metrics.MsgErrors.Inc()
-					continue
-				}
-
-				grpcSrv.BroadcastValue(msg.Device, msg.Parameter, msg.Value, time.Now().Unix())
-				metrics.ProcessingTime.Observe(time.Since(start).Seconds())
-			}
+			defer workers.Done()
+			batcher.Run()
 		}()
 	}
-	// Если буфер полон, то дропаем сообщение, иначе запись в буфер
-	mqttHandler := func(device, parameter, value string) {
+	// Переполнение обрабатывается очередью по политике INGEST_QUEUE_OVERFLOW
+	mqttHandler := func(device, parameter, value string, sourceTime time.Time, redelivered bool) {
+		msg := ingest.Message{Device: device, Parameter: parameter, Value: value, Timestamp: time.Now()}
+		if cfg.ClusterEnabled {
+			msg.Deduplicate(sourceTime, redelivered, time.Duration(cfg.ClusterDedupWindowMs)*time.Millisecond)
+		}
+		ingestQueue.Push(msg)
+	}
+	// Meta-топики пишем в реестр отдельной горутиной, чтобы не тормозить обработчик MQTT
+	metaQueue := make(chan mqtt.MetaUpdate, cfg.MQTTIngestQueueSize)
+	go func() {
+		for u := range metaQueue {
+			if err := db.SaveMeta(u.Device, u.Control, u.Key, u.Value); err != nil {
+				logger.Log.Error().
+					Str("component", "metaWorker").
+					Str("device", u.Device).
+					Str("control", u.Control).
+					Err(err).
+					Msg("Failed to save meta")
+				metrics.MsgErrors.Inc()
+			}
+		}
+	}()
+	metaHandler := func(u mqtt.MetaUpdate) {
 		select {
-		case ingestQueue <- IngestMessage{device, parameter, value}:
-			metrics.MsgReceived.Inc()
-			metrics.IngestQueueLength.Set(float64(len(ingestQueue)))
+		case metaQueue <- u:
 		default:
 			metrics.DroppedMessages.Inc()
 			logger.Log.Warn().
 				Str("component", "mqttHandler").
-				Str("device", device).
-				Str("parameter", parameter).
-				Msg("Dropped incoming message — ingestQueue full")
+				Str("device", u.Device).
+				Str("control", u.Control).
+				Msg("Dropped meta message — metaQueue full")
 		}
 	}
-	// Подключение к брокеру
-	mqttClient, err = mqtt.NewClient(
-		cfg.MQTTHost,
-		cfg.MQTTClientID,
-		cfg.MQTTTopics,
-		cfg.MQTTSubscribeQoS,
-		cfg.MQTTPublishQoS,
-		cfg.MQTTUsername,
-		cfg.MQTTPassword,
-		mqttHandler,
-	)
-	if err != nil {
-		logger.Log.Fatal().Str("component", "main").Err(err).Msg("MQTT client init failed")
+
+	// Подключение к брокерам в фоне: недоступный брокер не мешает остальным площадкам,
+	// попытки повторяются, а команды для его площадки до подключения отклоняются.
+	// Все брокеры пишут в общую очередь приема
+	for _, broker := range cfg.MQTTBrokers {
+		// TLS к брокеру; без своих настроек ssl:// и wss:// проверяются по системным CA
+		var mqttTLS *tls.Config
+		if broker.TLSEnabled() {
+			reloader, err := tlsutil.NewReloader(broker.TLSCertFile, broker.TLSKeyFile, broker.TLSCAFile)
+			if err != nil {
+				logger.Log.Fatal().Str("component", "main").Str("broker", broker.Name).Err(err).Msg("MQTT TLS init failed")
+			}
+			mqttTLS = reloader.ClientConfig(broker.ServerName(), broker.TLSInsecure)
+			if broker.TLSInsecure {
+				logger.Log.Warn().Str("component", "main").Str("broker", broker.Name).Msg("MQTT broker certificate verification disabled")
+			}
+		}
+
+		mqttRouter.Connect(mqtt.Options{
+			BrokerURL:       broker.Host,
+			ClientID:        broker.ClientID,
+			ProtocolVersion: broker.Version,
+			Username:        broker.Username,
+			Password:        broker.Password,
+			TLS:             mqttTLS,
+			Site:            broker.Site,
+			Topics:          broker.Topics,
+			Patterns:        cfg.TopicPatterns,
+			MetaTopics:      cfg.MQTTMetaTopics,
+			SharedGroup:     cfg.MQTTSharedGroup,
+			SubscribeQoS:    broker.SubscribeQoS,
+			PublishQoS:      broker.PublishQoS,
+			CommandSuffix:   cfg.CommandTopicSuffix,
+			CommandTimeout:  time.Duration(cfg.CommandTimeoutMs) * time.Millisecond,
+			ContentType:     cfg.MQTTContentType,
+			UserProperties:  cfg.MQTTUserProperties,
+			ResponseTopic:   broker.ResponseTopic,
+		}, mqttHandler, metaHandler)
+	}
+
+	// Текущие значения устройств в /metrics рядом со служебными метриками
+	if cfg.ExportValues {
+		prometheus.MustRegister(exporter.NewCollector(db, cfg.ExportValuesAllow, cfg.ExportValuesDeny))
 	}
-	grpcSrv.SetMQTTClient(mqttClient)
 
 	http.Handle("/metrics", promhttp.Handler())
 	go func() {
@@ -129,7 +258,44 @@ func main() {
 		}
 	}()
 
-	if err := grpcSrv.Start(cfg.GRPCPort); err != nil {
-		logger.Log.Fatal().Str("component", "main").Err(err).Msg("gRPC server failed")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := grpcSrv.Start(cfg.GRPCPort); err != nil {
+			logger.Log.Fatal().Str("component", "main").Err(err).Msg("gRPC server failed")
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Log.Info().Str("component", "main").Msg("Shutting down")
+
+	// Сначала перестаем принимать команды и сообщения, затем дописываем очередь:
+	// журнал на диске сохраняет контрольную точку, чтобы не повторять записанное
+	grpcSrv.Stop()
+	mqttRouter.Close()
+	if err := ingestQueue.Close(); err != nil {
+		logger.Log.Error().Str("component", "main").Err(err).Msg("Failed to close ingest queue")
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-time.After(15 * time.Second):
+		logger.Log.Warn().Str("component", "main").Msg("Ingest workers did not finish in time")
+	}
+
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			logger.Log.Error().Str("component", "main").Err(err).Msg("Failed to close sink")
+		}
+	}
+	if err := db.Close(); err != nil {
+		logger.Log.Error().Str("component", "main").Err(err).Msg("Failed to close database")
 	}
+	logger.Log.Info().Str("component", "main").Msg("Stopped")
 }
@@ -0,0 +1,137 @@
+// internal/mqttreceiver/auth/auth.go
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"brutus/internal/mqttreceiver/policy"
+)
+
+// Permission — действие, которое роль разрешает над рядами
+type Permission string
+
+const (
+	PermReadHistory Permission = "read_history" // история и агрегаты
+	PermSubscribe   Permission = "subscribe"    // живые и текущие значения
+	PermCommand     Permission = "command"      // отправка команд устройствам
+)
+
+// Grant — разрешение на ряды, подходящие под маски
+type Grant struct {
+	Permission Permission
+	Patterns   []policy.Pattern
+}
+
+// Roles — роли по имени
+type Roles map[string][]Grant
+
+// ParseRoles разбирает роли вида
+// "viewer=read_history:*,subscribe:*; operator=subscribe:*,command:wb-gpio/*|wb-mr6c_*/*".
+// Маски разрешения разделяются "|" и записываются как в правилах хранения.
+func ParseRoles(s string) (Roles, error) {
+	roles := make(Roles)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("role %q: expected name=permission:patterns", item)
+		}
+
+		var grants []Grant
+		for _, g := range strings.Split(spec, ",") {
+			perm, patterns, ok := strings.Cut(strings.TrimSpace(g), ":")
+			if !ok {
+				return nil, fmt.Errorf("role %q: grant %q: expected permission:patterns", name, g)
+			}
+			grant := Grant{Permission: Permission(strings.TrimSpace(perm))}
+			switch grant.Permission {
+			case PermReadHistory, PermSubscribe, PermCommand:
+			default:
+				return nil, fmt.Errorf("role %q: unknown permission %q", name, perm)
+			}
+			for _, p := range strings.Split(patterns, "|") {
+				pattern, err := policy.ParsePattern(p)
+				if err != nil {
+					return nil, fmt.Errorf("role %q: %v", name, err)
+				}
+				grant.Patterns = append(grant.Patterns, pattern)
+			}
+			grants = append(grants, grant)
+		}
+		roles[name] = grants
+	}
+	return roles, nil
+}
+
+// Identity — аутентифицированный клиент и его разрешения.
+// nil означает, что аутентификация выключена и разрешено все.
+type Identity struct {
+	Name   string
+	Roles  []string
+	grants []Grant
+}
+
+// newIdentity собирает разрешения ролей; неизвестная роль — ошибка
+func newIdentity(name string, roleNames []string, roles Roles) (*Identity, error) {
+	id := &Identity{Name: name, Roles: roleNames}
+	for _, r := range roleNames {
+		grants, ok := roles[r]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", r)
+		}
+		id.grants = append(id.grants, grants...)
+	}
+	return id, nil
+}
+
+// Can проверяет разрешение на ряд
+func (id *Identity) Can(perm Permission, device, parameter string) bool {
+	if id == nil {
+		return true
+	}
+	for _, g := range id.grants {
+		if g.Permission == perm && policy.MatchAny(g.Patterns, device, parameter) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAny проверяет, есть ли разрешение хотя бы на какие-то ряды
+func (id *Identity) CanAny(perm Permission) bool {
+	if id == nil {
+		return true
+	}
+	for _, g := range id.grants {
+		if g.Permission == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSee проверяет, разрешено ли клиенту хоть что-то над рядом; используется для реестра устройств
+func (id *Identity) CanSee(device, parameter string) bool {
+	return id.Can(PermReadHistory, device, parameter) ||
+		id.Can(PermSubscribe, device, parameter) ||
+		id.Can(PermCommand, device, parameter)
+}
+
+type identityKey struct{}
+
+// WithIdentity сохраняет клиента в контексте запроса
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext возвращает клиента запроса; nil — аутентификация выключена
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
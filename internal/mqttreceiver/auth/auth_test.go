@@ -0,0 +1,94 @@
+// internal/mqttreceiver/auth/auth_test.go
+package auth
+
+import "testing"
+
+func TestParseRoles(t *testing.T) {
+	tests := []struct {
+		s       string
+		roles   []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"viewer=read_history:*,subscribe:*; operator=subscribe:*,command:wb-gpio/*|wb-mr6c_*/*", []string{"viewer", "operator"}, false},
+		{" viewer = read_history : wb-adc/* ;; ", []string{"viewer"}, false},
+		{"viewer", nil, true},
+		{"=read_history:*", nil, true},
+		{"viewer=read_history", nil, true},
+		{"viewer=write:*", nil, true},
+		{"viewer=read_history:", nil, true},
+		{"viewer=read_history:wb-adc/*|", nil, true},
+		{"viewer=read_history:wb-adc/[", nil, true},
+	}
+	for _, tt := range tests {
+		roles, err := ParseRoles(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRoles(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if len(roles) != len(tt.roles) {
+			t.Errorf("ParseRoles(%q) = %d roles, want %v", tt.s, len(roles), tt.roles)
+		}
+		for _, name := range tt.roles {
+			if _, ok := roles[name]; !ok {
+				t.Errorf("ParseRoles(%q): role %q missing", tt.s, name)
+			}
+		}
+	}
+}
+
+func TestIdentityCan(t *testing.T) {
+	roles, err := ParseRoles("viewer=read_history:*; operator=subscribe:wb-gpio/*|*/uptime,command:wb-gpio/K?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := newIdentity("v", []string{"viewer"}, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operator, err := newIdentity("o", []string{"operator"}, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := newIdentity("b", []string{"viewer", "operator"}, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		id        *Identity
+		perm      Permission
+		device    string
+		parameter string
+		want      bool
+	}{
+		{"auth disabled", nil, PermCommand, "wb-gpio", "K1", true},
+		{"viewer history", viewer, PermReadHistory, "wb-adc", "A1", true},
+		{"viewer subscribe", viewer, PermSubscribe, "wb-adc", "A1", false},
+		{"viewer command", viewer, PermCommand, "wb-gpio", "K1", false},
+		{"operator subscribe device", operator, PermSubscribe, "wb-gpio", "A1", true},
+		{"operator subscribe second pattern", operator, PermSubscribe, "system", "uptime", true},
+		{"operator subscribe other", operator, PermSubscribe, "wb-adc", "A1", false},
+		{"operator command", operator, PermCommand, "wb-gpio", "K2", true},
+		{"operator command outside glob", operator, PermCommand, "wb-gpio", "K10", false},
+		{"operator history", operator, PermReadHistory, "wb-gpio", "K1", false},
+		{"roles combined", both, PermReadHistory, "wb-gpio", "K1", true},
+		{"roles combined command", both, PermCommand, "wb-gpio", "K1", true},
+	}
+	for _, tt := range tests {
+		if got := tt.id.Can(tt.perm, tt.device, tt.parameter); got != tt.want {
+			t.Errorf("%s: Can(%s, %s/%s) = %v, want %v", tt.name, tt.perm, tt.device, tt.parameter, got, tt.want)
+		}
+	}
+
+	if viewer.CanAny(PermCommand) || !operator.CanAny(PermCommand) {
+		t.Error("CanAny does not follow role grants")
+	}
+	if !operator.CanSee("wb-gpio", "A1") || operator.CanSee("wb-adc", "A1") || !viewer.CanSee("wb-adc", "A1") {
+		t.Error("CanSee does not follow role grants")
+	}
+	if _, err := newIdentity("x", []string{"admin"}, roles); err == nil {
+		t.Error("unknown role accepted")
+	}
+}
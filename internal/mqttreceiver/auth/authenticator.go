@@ -0,0 +1,198 @@
+// internal/mqttreceiver/auth/authenticator.go
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig — проверка JWT локальным ключом
+type JWTConfig struct {
+	KeyFile    string // HMAC-секрет или публичный ключ RSA/ECDSA/Ed25519 в PEM
+	Issuer     string // пусто — не проверяется
+	Audience   string // пусто — не проверяется
+	RolesClaim string // claim со списком ролей
+}
+
+// Authenticator проверяет API-ключи и JWT и сопоставляет им роли
+type Authenticator struct {
+	roles   Roles
+	apiKeys map[[sha256.Size]byte]*Identity
+	certs   []certIdentity
+
+	jwtKey     any
+	jwtMethods []string
+	jwtCfg     JWTConfig
+}
+
+// certIdentity — роли клиентов, чей CN сертификата подходит под glob-маску
+type certIdentity struct {
+	cnPattern string
+	roles     []string
+}
+
+// NewAuthenticator создает проверку по ролям, API-ключам вида "key=name:role1|role2; ...",
+// клиентским сертификатам вида "cn-маска=role1|role2; ..." и, если задан KeyFile, по JWT
+func NewAuthenticator(roles Roles, apiKeys, certIdentities string, jwtCfg JWTConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		roles:   roles,
+		apiKeys: make(map[[sha256.Size]byte]*Identity),
+		jwtCfg:  jwtCfg,
+	}
+
+	for _, item := range strings.Split(apiKeys, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, spec, ok := strings.Cut(item, "=")
+		name, roleList, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 || strings.TrimSpace(key) == "" || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("api key entry must be key=name:role1|role2")
+		}
+		id, err := newIdentity(strings.TrimSpace(name), splitRoles(roleList), roles)
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %v", name, err)
+		}
+		// Ключи храним хешами: поиск по хешу не выдает содержимое ключа по времени сравнения
+		a.apiKeys[sha256.Sum256([]byte(strings.TrimSpace(key)))] = id
+	}
+
+	for _, item := range strings.Split(certIdentities, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		cn, roleList, ok := strings.Cut(item, "=")
+		cn = strings.TrimSpace(cn)
+		if !ok || cn == "" {
+			return nil, fmt.Errorf("certificate identity entry must be cn=role1|role2")
+		}
+		if _, err := path.Match(cn, ""); err != nil {
+			return nil, fmt.Errorf("certificate identity %q: %v", cn, err)
+		}
+		// Роли проверяем сразу, чтобы ошибка конфигурации не ждала первого клиента
+		if _, err := newIdentity(cn, splitRoles(roleList), roles); err != nil {
+			return nil, fmt.Errorf("certificate identity %q: %v", cn, err)
+		}
+		a.certs = append(a.certs, certIdentity{cnPattern: cn, roles: splitRoles(roleList)})
+	}
+
+	if jwtCfg.KeyFile != "" {
+		if err := a.loadJWTKey(jwtCfg.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// loadJWTKey читает ключ подписи: PEM — публичный ключ, иначе HMAC-секрет
+func (a *Authenticator) loadJWTKey(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read jwt key: %v", err)
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			a.jwtKey, a.jwtMethods = key, []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+			return nil
+		}
+		if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+			a.jwtKey, a.jwtMethods = key, []string{"ES256", "ES384", "ES512"}
+			return nil
+		}
+		if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+			a.jwtKey, a.jwtMethods = key, []string{"EdDSA"}
+			return nil
+		}
+		return fmt.Errorf("jwt key: unsupported PEM block %q", block.Type)
+	}
+
+	secret := []byte(strings.TrimSpace(string(data)))
+	if len(secret) < 32 {
+		return fmt.Errorf("jwt key: HMAC secret must be at least 32 bytes")
+	}
+	a.jwtKey, a.jwtMethods = secret, []string{"HS256", "HS384", "HS512"}
+	return nil
+}
+
+// Authenticate возвращает клиента по API-ключу или JWT
+func (a *Authenticator) Authenticate(token string) (*Identity, error) {
+	if token == "" {
+		return nil, fmt.Errorf("missing credentials")
+	}
+
+	if id, ok := a.apiKeys[sha256.Sum256([]byte(token))]; ok {
+		return id, nil
+	}
+
+	if a.jwtKey == nil {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	return a.parseJWT(token)
+}
+
+// AuthenticateCertificate возвращает клиента по проверенному сертификату mTLS; первое подходящее правило
+func (a *Authenticator) AuthenticateCertificate(cert *x509.Certificate) (*Identity, error) {
+	cn := cert.Subject.CommonName
+	for _, c := range a.certs {
+		if ok, _ := path.Match(c.cnPattern, cn); ok {
+			return newIdentity(cn, c.roles, a.roles)
+		}
+	}
+	return nil, fmt.Errorf("no roles for client certificate %q", cn)
+}
+
+func (a *Authenticator) parseJWT(token string) (*Identity, error) {
+	opts := []jwt.ParserOption{jwt.WithValidMethods(a.jwtMethods), jwt.WithExpirationRequired()}
+	if a.jwtCfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.jwtCfg.Issuer))
+	}
+	if a.jwtCfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.jwtCfg.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return a.jwtKey, nil
+	}, opts...); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	name, _ := claims.GetSubject()
+	if name == "" {
+		return nil, fmt.Errorf("invalid token: missing sub")
+	}
+
+	var roleNames []string
+	switch v := claims[a.jwtCfg.RolesClaim].(type) {
+	case string:
+		roleNames = splitRoles(v)
+	case []any:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roleNames = append(roleNames, s)
+			}
+		}
+	}
+	// Неизвестные роли из токена пропускаем: токены выпускает внешний сервис
+	var known []string
+	for _, r := range roleNames {
+		if _, ok := a.roles[r]; ok {
+			known = append(known, r)
+		}
+	}
+	return newIdentity(name, known, a.roles)
+}
+
+func splitRoles(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool { return c == '|' || c == ' ' || c == ',' })
+}
@@ -0,0 +1,120 @@
+// internal/mqttreceiver/auth/interceptor.go
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"brutus/internal/mqttreceiver/logger"
+	pb "brutus/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Какие разрешения нужны методам; подойдет любое из списка.
+// Доступ к конкретным рядам проверяют сами обработчики.
+var methodPermissions = map[string][]Permission{
+	pb.MQTTReceiver_DataExchange_FullMethodName:         {PermSubscribe, PermCommand},
+	pb.MQTTReceiver_GetHistory_FullMethodName:           {PermReadHistory},
+	pb.MQTTReceiver_GetMultiHistory_FullMethodName:      {PermReadHistory},
+	pb.MQTTReceiver_StreamHistory_FullMethodName:        {PermReadHistory},
+	pb.MQTTReceiver_GetAggregatedHistory_FullMethodName: {PermReadHistory},
+	pb.MQTTReceiver_GetCurrentValues_FullMethodName:     {PermSubscribe},
+	pb.MQTTReceiver_GetDevices_FullMethodName:           {PermReadHistory, PermSubscribe, PermCommand},
+}
+
+// UnaryInterceptor аутентифицирует unary-вызовы
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor аутентифицирует потоковые вызовы
+func (a *Authenticator) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorize проверяет учетные данные и разрешение на метод
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	id, err := a.identify(ctx)
+	if err != nil {
+		logger.Log.Warn().
+			Str("component", "auth").
+			Str("method", method).
+			Err(err).
+			Msg("Authentication failed")
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if perms, ok := methodPermissions[method]; ok {
+		allowed := false
+		for _, p := range perms {
+			if id.CanAny(p) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			logger.Log.Warn().
+				Str("component", "auth").
+				Str("method", method).
+				Str("identity", id.Name).
+				Msg("Permission denied")
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+	}
+	return WithIdentity(ctx, id), nil
+}
+
+// identify определяет клиента: по токену, а без него — по проверенному сертификату mTLS
+func (a *Authenticator) identify(ctx context.Context) (*Identity, error) {
+	if token := tokenFromMetadata(ctx); token != "" {
+		return a.Authenticate(token)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return a.AuthenticateCertificate(info.State.VerifiedChains[0][0])
+		}
+	}
+	return nil, fmt.Errorf("missing credentials")
+}
+
+// tokenFromMetadata берет токен из "authorization: Bearer ..." или "x-api-key"
+func tokenFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get("authorization"); len(v) > 0 {
+		if token, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return strings.TrimSpace(v[0])
+	}
+	return ""
+}
+
+// identityStream подменяет контекст потока контекстом с клиентом
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
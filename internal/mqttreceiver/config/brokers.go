@@ -0,0 +1,223 @@
+// internal/mqttreceiver/config/brokers.go
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MQTTBroker — подключение к одному брокеру
+type MQTTBroker struct {
+	Name          string // имя из MQTT_BROKERS; пусто — единственный брокер из MQTT_*
+	Site          string // префикс устройств "<site>:<device>"; пусто — без префикса
+	Host          string
+	ClientID      string
+	Username      string
+	Password      string
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSInsecure   bool
+	Version       int
+	SubscribeQoS  byte
+	PublishQoS    byte
+	Topics        []string
+	ResponseTopic string
+}
+
+// TLSEnabled сообщает, заданы ли собственные настройки TLS для брокера
+func (b *MQTTBroker) TLSEnabled() bool {
+	return b.TLSCAFile != "" || b.TLSCertFile != "" || b.TLSInsecure
+}
+
+// ServerName возвращает имя или IP-адрес брокера для проверки его сертификата
+func (b *MQTTBroker) ServerName() string {
+	brokerURL, err := url.Parse(b.Host)
+	if err != nil {
+		return ""
+	}
+	return brokerURL.Hostname()
+}
+
+// loadBrokers строит список брокеров: единственный из MQTT_* или перечисленные в MQTT_BROKERS.
+// Брокер <name> настраивается переменными MQTT_<NAME>_*; версия протокола, QoS и топики
+// без своих значений берутся из общих MQTT_*, адрес, учетные данные и TLS — только свои.
+func loadBrokers(cfg *Config, baseClientID string) ([]MQTTBroker, error) {
+	namesEnv := os.Getenv("MQTT_BROKERS")
+	if strings.TrimSpace(namesEnv) == "" {
+		b := MQTTBroker{
+			Host:          cfg.MQTTHost,
+			ClientID:      cfg.MQTTClientID,
+			Username:      cfg.MQTTUsername,
+			Password:      cfg.MQTTPassword,
+			TLSCAFile:     cfg.MQTTTLSCAFile,
+			TLSCertFile:   cfg.MQTTTLSCertFile,
+			TLSKeyFile:    cfg.MQTTTLSKeyFile,
+			TLSInsecure:   cfg.MQTTTLSInsecure,
+			Version:       cfg.MQTTVersion,
+			SubscribeQoS:  cfg.MQTTSubscribeQoS,
+			PublishQoS:    cfg.MQTTPublishQoS,
+			Topics:        cfg.MQTTTopics,
+			ResponseTopic: cfg.MQTTResponseTopic,
+		}
+		if err := validateBroker("MQTT_", b); err != nil {
+			return nil, err
+		}
+		return []MQTTBroker{b}, nil
+	}
+
+	var brokers []MQTTBroker
+	sites := make(map[string]bool)
+	for _, name := range strings.Split(namesEnv, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.Trim(strings.ToUpper(name), "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") != "" {
+			return nil, fmt.Errorf("invalid MQTT_BROKERS: name %q must contain only letters, digits and _", name)
+		}
+
+		prefix := "MQTT_" + strings.ToUpper(name) + "_"
+		env := func(key, def string) string {
+			if v := os.Getenv(prefix + key); v != "" {
+				return v
+			}
+			return def
+		}
+
+		b := MQTTBroker{
+			Name:         name,
+			Site:         env("SITE", name),
+			Host:         os.Getenv(prefix + "BROKER"),
+			ClientID:     env("CLIENT_ID", baseClientID+"-"+name),
+			Username:     os.Getenv(prefix + "USERNAME"),
+			Password:     os.Getenv(prefix + "PASSWORD"),
+			TLSCAFile:    os.Getenv(prefix + "TLS_CA_FILE"),
+			TLSCertFile:  os.Getenv(prefix + "TLS_CERT_FILE"),
+			TLSKeyFile:   os.Getenv(prefix + "TLS_KEY_FILE"),
+			Version:      cfg.MQTTVersion,
+			SubscribeQoS: cfg.MQTTSubscribeQoS,
+			PublishQoS:   cfg.MQTTPublishQoS,
+			Topics:       cfg.MQTTTopics,
+		}
+		if b.Host == "" {
+			return nil, fmt.Errorf("%sBROKER is required", prefix)
+		}
+		if cfg.ClusterEnabled {
+			b.ClientID += "-" + cfg.ClusterInstanceID
+		}
+
+		if strings.ContainsAny(b.Site, ":/+#") {
+			return nil, fmt.Errorf("invalid %sSITE: must not contain :, /, + or #", prefix)
+		}
+		if sites[b.Site] {
+			return nil, fmt.Errorf("invalid %sSITE: site %q is used by another broker", prefix, b.Site)
+		}
+		sites[b.Site] = true
+
+		if insecureStr := os.Getenv(prefix + "TLS_INSECURE_SKIP_VERIFY"); insecureStr != "" {
+			v, err := strconv.ParseBool(insecureStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %sTLS_INSECURE_SKIP_VERIFY", prefix)
+			}
+			b.TLSInsecure = v
+		}
+
+		switch v := os.Getenv(prefix + "PROTOCOL_VERSION"); v {
+		case "":
+		case "3", "3.1.1":
+			b.Version = 3
+		case "5":
+			b.Version = 5
+		default:
+			return nil, fmt.Errorf("invalid %sPROTOCOL_VERSION: must be 3 or 5", prefix)
+		}
+
+		for key, qos := range map[string]*byte{"SUBSCRIBE_QOS": &b.SubscribeQoS, "PUBLISH_QOS": &b.PublishQoS} {
+			qosStr := os.Getenv(prefix + key)
+			if qosStr == "" {
+				continue
+			}
+			v, err := strconv.Atoi(qosStr)
+			if err != nil || v < 0 || v > 2 {
+				return nil, fmt.Errorf("invalid %s%s: must be 0, 1 or 2", prefix, key)
+			}
+			*qos = byte(v)
+		}
+
+		if topicsEnv := os.Getenv(prefix + "TOPICS"); topicsEnv != "" {
+			b.Topics = nil
+			for _, t := range strings.Split(topicsEnv, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					b.Topics = append(b.Topics, t)
+				}
+			}
+		}
+
+		// Ответы на команды MQTT 5: свой топик у каждого брокера, MQTT_RESPONSE_TOPIC=none отключает все
+		if b.Version == 5 {
+			switch b.ResponseTopic = os.Getenv(prefix + "RESPONSE_TOPIC"); b.ResponseTopic {
+			case "":
+				if os.Getenv("MQTT_RESPONSE_TOPIC") != "none" {
+					b.ResponseTopic = "brutus/responses/" + b.ClientID
+				}
+			case "none":
+				b.ResponseTopic = ""
+			}
+			if strings.ContainsAny(b.ResponseTopic, "+#") {
+				return nil, fmt.Errorf("invalid %sRESPONSE_TOPIC: must not contain wildcards", prefix)
+			}
+		}
+
+		if err := validateBroker(prefix, b); err != nil {
+			return nil, err
+		}
+		brokers = append(brokers, b)
+	}
+
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("invalid MQTT_BROKERS: no brokers listed")
+	}
+	return brokers, nil
+}
+
+// validateBroker проверяет адрес и настройки TLS брокера; prefix — начало имен переменных
+func validateBroker(prefix string, b MQTTBroker) error {
+	// Брокер: tcp:// или mqtt://, ssl://, tls:// или mqtts://, ws:// или wss://
+	brokerURL, err := url.Parse(b.Host)
+	if err != nil || brokerURL.Host == "" {
+		return fmt.Errorf("invalid %sBROKER", prefix)
+	}
+	var brokerTLS bool
+	switch brokerURL.Scheme {
+	case "tcp", "mqtt", "ws":
+	case "ssl", "tls", "mqtts", "wss":
+		brokerTLS = true
+	default:
+		return fmt.Errorf("invalid %sBROKER: unsupported scheme %q", prefix, brokerURL.Scheme)
+	}
+
+	// TLS к брокеру: свой CA, клиентский сертификат; файлы перечитываются при изменении
+	if (b.TLSCertFile == "") != (b.TLSKeyFile == "") {
+		return fmt.Errorf("%sTLS_CERT_FILE and %sTLS_KEY_FILE must be set together", prefix, prefix)
+	}
+	if b.TLSEnabled() && !brokerTLS {
+		return fmt.Errorf("%sTLS_* settings require an ssl://, tls://, mqtts:// or wss:// %sBROKER", prefix, prefix)
+	}
+	for key, file := range map[string]string{
+		"TLS_CA_FILE":   b.TLSCAFile,
+		"TLS_CERT_FILE": b.TLSCertFile,
+		"TLS_KEY_FILE":  b.TLSKeyFile,
+	} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("invalid %s%s: %v", prefix, key, err)
+		}
+	}
+	return nil
+}
@@ -7,25 +7,84 @@ import (
 	"strconv"
 	"strings"
 
+	"brutus/internal/mqttreceiver/auth"
+	"brutus/internal/mqttreceiver/grpc"
+	"brutus/internal/mqttreceiver/ingest"
+	"brutus/internal/mqttreceiver/mqtt"
+	"brutus/internal/mqttreceiver/policy"
+	"brutus/internal/mqttreceiver/tlsutil"
+	"brutus/internal/mqttreceiver/topic"
+
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	MQTTHost             string
-	MQTTClientID         string
-	MQTTUsername         string
-	MQTTPassword         string
-	MQTTSubscribeQoS     byte
-	MQTTPublishQoS       byte
-	MQTTTopics           []string
-	TopicPattern         string
-	DBFile               string
-	GRPCPort             int
-	MetricsPort          int
-	LogLevel             string
-	HistoryRetentionDays int
-	MQTTIngestQueueSize  int
-	WorkerCount          int
+	MQTTHost              string
+	MQTTClientID          string
+	MQTTUsername          string
+	MQTTPassword          string
+	MQTTTLSCAFile         string
+	MQTTTLSCertFile       string
+	MQTTTLSKeyFile        string
+	MQTTTLSInsecure       bool
+	MQTTVersion           int
+	MQTTSharedGroup       string
+	MQTTContentType       string
+	MQTTUserProperties    []mqtt.UserProperty
+	MQTTResponseTopic     string
+	MQTTBrokers           []MQTTBroker
+	ClusterEnabled        bool
+	ClusterInstanceID     string
+	ClusterDedupWindowMs  int
+	MQTTSubscribeQoS      byte
+	MQTTPublishQoS        byte
+	MQTTTopics            []string
+	MQTTMetaTopics        []string
+	CommandTopicSuffix    string
+	CommandTimeoutMs      int
+	TopicPatterns         []*topic.Pattern
+	DBDriver              string
+	DBFile                string
+	DBDSN                 string
+	GRPCPort              int
+	GRPCReplayBufferSize  int
+	GRPCSubscriberBuffer  int
+	GRPCSubscriberMax     int
+	GRPCSlowConsumer      grpc.SlowConsumerPolicy
+	AuthEnabled           bool
+	AuthRoles             auth.Roles
+	AuthAPIKeys           string
+	AuthCertIdentities    string
+	AuthJWT               auth.JWTConfig
+	GRPCTLSCertFile       string
+	GRPCTLSKeyFile        string
+	GRPCTLSClientCAFile   string
+	GRPCTLSClientAuth     tlsutil.ClientAuth
+	MetricsPort           int
+	LogLevel              string
+	HistoryRetentionDays  int
+	RetentionRules        policy.RetentionRules
+	RecordingRules        policy.RecordingRules
+	RollupIntervalSec     int
+	RollupRetentionDays   map[string]int
+	MQTTIngestQueueSize   int
+	WorkerCount           int
+	IngestBatchSize       int
+	IngestBatchIntervalMs int
+	DBMaxOpenConns        int
+	IngestQueueDir        string
+	IngestQueueMaxBytes   int64
+	IngestQueueOverflow   ingest.OverflowPolicy
+	InfluxURL             string
+	InfluxToken           string
+	InfluxMeasurement     string
+	InfluxBatchSize       int
+	InfluxFlushIntervalMs int
+	InfluxSpoolFile       string
+	InfluxSpoolMaxBytes   int64
+	ExportValues          bool
+	ExportValuesAllow     []policy.Pattern
+	ExportValuesDeny      []policy.Pattern
 }
 
 func LoadConfig() (*Config, error) {
@@ -36,7 +95,9 @@ func LoadConfig() (*Config, error) {
 		MQTTClientID: os.Getenv("MQTT_CLIENT_ID"),
 		MQTTUsername: os.Getenv("MQTT_USERNAME"),
 		MQTTPassword: os.Getenv("MQTT_PASSWORD"),
+		DBDriver:     os.Getenv("DB_DRIVER"),
 		DBFile:       os.Getenv("DB_FILE"),
+		DBDSN:        os.Getenv("DB_DSN"),
 		LogLevel:     os.Getenv("LOG_LEVEL"),
 	}
 
@@ -46,13 +107,124 @@ func LoadConfig() (*Config, error) {
 	if cfg.MQTTClientID == "" {
 		cfg.MQTTClientID = "mqttreceiver"
 	}
+
+	// TLS к брокеру проверяется вместе с адресом в validateBroker
+	cfg.MQTTTLSCAFile = os.Getenv("MQTT_TLS_CA_FILE")
+	cfg.MQTTTLSCertFile = os.Getenv("MQTT_TLS_CERT_FILE")
+	cfg.MQTTTLSKeyFile = os.Getenv("MQTT_TLS_KEY_FILE")
+	if insecureStr := os.Getenv("MQTT_TLS_INSECURE_SKIP_VERIFY"); insecureStr != "" {
+		v, err := strconv.ParseBool(insecureStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MQTT_TLS_INSECURE_SKIP_VERIFY")
+		}
+		cfg.MQTTTLSInsecure = v
+	}
 	if cfg.DBFile == "" {
 		cfg.DBFile = "brutus.db"
 	}
-	if cfg.TopicPattern == "" {
-		cfg.TopicPattern = "{device_id}/{control_id}"
+
+	// Хранилище: sqlite (DB_FILE) или postgres/timescale (DB_DSN)
+	switch cfg.DBDriver {
+	case "":
+		cfg.DBDriver = "sqlite"
+		fallthrough
+	case "sqlite":
+		cfg.DBDSN = cfg.DBFile
+	case "postgres", "timescale":
+		if cfg.DBDSN == "" {
+			return nil, fmt.Errorf("DB_DSN is required for DB_DRIVER=%s", cfg.DBDriver)
+		}
+	default:
+		return nil, fmt.Errorf("invalid DB_DRIVER: must be sqlite, postgres or timescale")
+	}
+
+	// Версия протокола: 3 (MQTT 3.1.1) или 5
+	switch v := os.Getenv("MQTT_PROTOCOL_VERSION"); v {
+	case "", "3", "3.1.1":
+		cfg.MQTTVersion = 3
+	case "5":
+		cfg.MQTTVersion = 5
+	default:
+		return nil, fmt.Errorf("invalid MQTT_PROTOCOL_VERSION: must be 3 or 5")
+	}
+
+	// Кластерный режим: у каждой реплики свой client ID, значения делятся общей подпиской,
+	// а история пишется идемпотентно, чтобы повторно доставленное сообщение не задвоилось
+	if clusterStr := os.Getenv("CLUSTER_ENABLED"); clusterStr != "" {
+		v, err := strconv.ParseBool(clusterStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLUSTER_ENABLED")
+		}
+		cfg.ClusterEnabled = v
+	}
+	baseClientID := cfg.MQTTClientID
+	if cfg.ClusterEnabled {
+		cfg.ClusterInstanceID = os.Getenv("CLUSTER_INSTANCE_ID")
+		if cfg.ClusterInstanceID == "" {
+			host, err := os.Hostname()
+			if err != nil {
+				return nil, fmt.Errorf("CLUSTER_INSTANCE_ID is required: %v", err)
+			}
+			cfg.ClusterInstanceID = host
+		}
+		if strings.ContainsAny(cfg.ClusterInstanceID, "/+#") {
+			return nil, fmt.Errorf("invalid CLUSTER_INSTANCE_ID: must not contain /, + or #")
+		}
+		cfg.MQTTClientID = baseClientID + "-" + cfg.ClusterInstanceID
+	}
+
+	if windowStr := os.Getenv("CLUSTER_DEDUP_WINDOW_MS"); windowStr != "" {
+		if window, err := strconv.Atoi(windowStr); err == nil && window > 0 {
+			cfg.ClusterDedupWindowMs = window
+		} else {
+			return nil, fmt.Errorf("invalid CLUSTER_DEDUP_WINDOW_MS")
+		}
+	} else {
+		cfg.ClusterDedupWindowMs = 60000
 	}
 
+	// Общая подписка $share/<group>/... делит поток значений между репликами
+	cfg.MQTTSharedGroup = os.Getenv("MQTT_SHARED_GROUP")
+	if cfg.MQTTSharedGroup == "" && cfg.ClusterEnabled {
+		cfg.MQTTSharedGroup = baseClientID
+	}
+	if strings.ContainsAny(cfg.MQTTSharedGroup, "/+#") {
+		return nil, fmt.Errorf("invalid MQTT_SHARED_GROUP: must not contain /, + or #")
+	}
+
+	// Свойства команд MQTT 5
+	cfg.MQTTContentType = os.Getenv("MQTT_CONTENT_TYPE")
+	userProperties, err := mqtt.ParseUserProperties(os.Getenv("MQTT_USER_PROPERTIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT_USER_PROPERTIES: %v", err)
+	}
+	cfg.MQTTUserProperties = userProperties
+	cfg.MQTTResponseTopic = os.Getenv("MQTT_RESPONSE_TOPIC")
+	if cfg.MQTTVersion == 5 {
+		switch cfg.MQTTResponseTopic {
+		case "":
+			cfg.MQTTResponseTopic = "brutus/responses/" + cfg.MQTTClientID
+		case "none":
+			cfg.MQTTResponseTopic = ""
+		}
+		if strings.ContainsAny(cfg.MQTTResponseTopic, "+#") {
+			return nil, fmt.Errorf("invalid MQTT_RESPONSE_TOPIC: must not contain wildcards")
+		}
+	} else if cfg.MQTTContentType != "" || len(cfg.MQTTUserProperties) > 0 || cfg.MQTTResponseTopic != "" {
+		return nil, fmt.Errorf("MQTT_CONTENT_TYPE, MQTT_USER_PROPERTIES and MQTT_RESPONSE_TOPIC require MQTT_PROTOCOL_VERSION=5")
+	}
+
+	// Шаблоны топиков: первый шаблон без масок используется и для публикации команд
+	patternsEnv := os.Getenv("TOPIC_PATTERN")
+	if patternsEnv == "" {
+		patternsEnv = "/devices/{device_id}/controls/{control_id}"
+	}
+	patterns, err := topic.ParseList(patternsEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOPIC_PATTERN: %v", err)
+	}
+	cfg.TopicPatterns = patterns
+
 	if qosStr := os.Getenv("MQTT_SUBSCRIBE_QOS"); qosStr != "" {
 		qos, err := strconv.Atoi(qosStr)
 		if err != nil || qos < 0 || qos > 2 {
@@ -79,7 +251,54 @@ func LoadConfig() (*Config, error) {
 			cfg.MQTTTopics[i] = strings.TrimSpace(topic)
 		}
 	} else {
-		cfg.MQTTTopics = []string{"/devices/+/controls/+"}
+		// Без явного списка подписываемся на фильтры, построенные из шаблонов
+		for _, p := range cfg.TopicPatterns {
+			cfg.MQTTTopics = append(cfg.MQTTTopics, p.Filter())
+		}
+	}
+
+	// Meta-топики Wiren Board для реестра устройств, "none" отключает подписку
+	if metaEnv := os.Getenv("MQTT_META_TOPICS"); metaEnv != "" {
+		if metaEnv != "none" {
+			for _, t := range strings.Split(metaEnv, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					cfg.MQTTMetaTopics = append(cfg.MQTTMetaTopics, t)
+				}
+			}
+		}
+	} else {
+		cfg.MQTTMetaTopics = []string{"/devices/+/meta/#", "/devices/+/controls/+/meta/#"}
+	}
+
+	// Брокеры: один из MQTT_* или несколько из MQTT_BROKERS с префиксами площадок
+	if cfg.MQTTBrokers, err = loadBrokers(cfg, baseClientID); err != nil {
+		return nil, err
+	}
+	// По общей подписке эхо состояния приходит случайной реплике, а не отправившей команду:
+	// подтвердить команду можно только ответом MQTT 5 в собственный топик реплики
+	if cfg.MQTTSharedGroup != "" {
+		for _, b := range cfg.MQTTBrokers {
+			if b.Version != 5 || b.ResponseTopic == "" {
+				return nil, fmt.Errorf("MQTT_SHARED_GROUP and CLUSTER_ENABLED require MQTT_PROTOCOL_VERSION=5 with a response topic for commands")
+			}
+		}
+	}
+
+	// Команды Wiren Board публикуются в <топик состояния>/on
+	if suffix, ok := os.LookupEnv("COMMAND_TOPIC_SUFFIX"); ok {
+		cfg.CommandTopicSuffix = strings.TrimSpace(suffix)
+	} else {
+		cfg.CommandTopicSuffix = "/on"
+	}
+
+	if timeoutStr := os.Getenv("COMMAND_TIMEOUT_MS"); timeoutStr != "" {
+		if t, err := strconv.Atoi(timeoutStr); err == nil && t > 0 {
+			cfg.CommandTimeoutMs = t
+		} else {
+			return nil, fmt.Errorf("invalid COMMAND_TIMEOUT_MS")
+		}
+	} else {
+		cfg.CommandTimeoutMs = 5000
 	}
 
 	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
@@ -92,6 +311,95 @@ func LoadConfig() (*Config, error) {
 		cfg.GRPCPort = 50051
 	}
 
+	if sizeStr := os.Getenv("GRPC_REPLAY_BUFFER_SIZE"); sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n >= 0 {
+			cfg.GRPCReplayBufferSize = n
+		} else {
+			return nil, fmt.Errorf("invalid GRPC_REPLAY_BUFFER_SIZE")
+		}
+	} else {
+		cfg.GRPCReplayBufferSize = 10000
+	}
+
+	if sizeStr := os.Getenv("GRPC_SUBSCRIBER_BUFFER"); sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n > 0 {
+			cfg.GRPCSubscriberBuffer = n
+		} else {
+			return nil, fmt.Errorf("invalid GRPC_SUBSCRIBER_BUFFER")
+		}
+	} else {
+		cfg.GRPCSubscriberBuffer = 100
+	}
+
+	if sizeStr := os.Getenv("GRPC_SUBSCRIBER_MAX_BUFFER"); sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n >= cfg.GRPCSubscriberBuffer {
+			cfg.GRPCSubscriberMax = n
+		} else {
+			return nil, fmt.Errorf("invalid GRPC_SUBSCRIBER_MAX_BUFFER: must be at least GRPC_SUBSCRIBER_BUFFER")
+		}
+	} else {
+		cfg.GRPCSubscriberMax = max(10000, cfg.GRPCSubscriberBuffer)
+	}
+
+	if policyStr := os.Getenv("GRPC_SLOW_CONSUMER_POLICY"); policyStr != "" {
+		p, err := grpc.ParseSlowConsumerPolicy(policyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRPC_SLOW_CONSUMER_POLICY: %v", err)
+		}
+		cfg.GRPCSlowConsumer = p
+	} else {
+		cfg.GRPCSlowConsumer = grpc.SlowConsumerDropNewest
+	}
+
+	// Аутентификация gRPC: API-ключи и/или JWT, права — по ролям
+	if authStr := os.Getenv("AUTH_ENABLED"); authStr != "" {
+		v, err := strconv.ParseBool(authStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_ENABLED")
+		}
+		cfg.AuthEnabled = v
+	}
+	if cfg.AuthRoles, err = auth.ParseRoles(os.Getenv("AUTH_ROLES")); err != nil {
+		return nil, fmt.Errorf("invalid AUTH_ROLES: %v", err)
+	}
+	cfg.AuthAPIKeys = os.Getenv("AUTH_API_KEYS")
+	cfg.AuthCertIdentities = os.Getenv("AUTH_CERT_IDENTITIES")
+	cfg.AuthJWT = auth.JWTConfig{
+		KeyFile:    os.Getenv("AUTH_JWT_KEY_FILE"),
+		Issuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+		RolesClaim: os.Getenv("AUTH_JWT_ROLES_CLAIM"),
+	}
+	if cfg.AuthJWT.RolesClaim == "" {
+		cfg.AuthJWT.RolesClaim = "roles"
+	}
+	if cfg.AuthEnabled && cfg.AuthAPIKeys == "" && cfg.AuthJWT.KeyFile == "" && cfg.AuthCertIdentities == "" {
+		return nil, fmt.Errorf("AUTH_ENABLED requires AUTH_API_KEYS, AUTH_JWT_KEY_FILE or AUTH_CERT_IDENTITIES")
+	}
+
+	// TLS gRPC включается заданием сертификата; сертификаты перечитываются при изменении файлов
+	cfg.GRPCTLSCertFile = os.Getenv("GRPC_TLS_CERT_FILE")
+	cfg.GRPCTLSKeyFile = os.Getenv("GRPC_TLS_KEY_FILE")
+	cfg.GRPCTLSClientCAFile = os.Getenv("GRPC_TLS_CLIENT_CA_FILE")
+	if (cfg.GRPCTLSCertFile == "") != (cfg.GRPCTLSKeyFile == "") {
+		return nil, fmt.Errorf("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together")
+	}
+
+	if clientAuth := os.Getenv("GRPC_TLS_CLIENT_AUTH"); clientAuth != "" {
+		c, err := tlsutil.ParseClientAuth(clientAuth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRPC_TLS_CLIENT_AUTH: %v", err)
+		}
+		cfg.GRPCTLSClientAuth = c
+	} else if cfg.GRPCTLSClientCAFile != "" {
+		cfg.GRPCTLSClientAuth = tlsutil.ClientAuthRequire
+	} else {
+		cfg.GRPCTLSClientAuth = tlsutil.ClientAuthNone
+	}
+	if cfg.GRPCTLSClientAuth != tlsutil.ClientAuthNone && (cfg.GRPCTLSCertFile == "" || cfg.GRPCTLSClientCAFile == "") {
+		return nil, fmt.Errorf("GRPC_TLS_CLIENT_AUTH requires GRPC_TLS_CERT_FILE and GRPC_TLS_CLIENT_CA_FILE")
+	}
+
 	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" {
 		if p, err := strconv.Atoi(metricsPort); err == nil {
 			cfg.MetricsPort = p
@@ -112,6 +420,50 @@ func LoadConfig() (*Config, error) {
 		cfg.HistoryRetentionDays = 7
 	}
 
+	// Правила хранения по маскам рядов; ряды без правила живут HISTORY_RETENTION_DAYS
+	if rulesStr := os.Getenv("HISTORY_RETENTION_RULES"); rulesStr != "" {
+		rules, err := policy.ParseRetention(rulesStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HISTORY_RETENTION_RULES: %v", err)
+		}
+		cfg.RetentionRules = rules
+	}
+
+	// Правила записи истории; ряды без правила пишутся на каждое сообщение
+	if rulesStr := os.Getenv("HISTORY_RECORDING_RULES"); rulesStr != "" {
+		rules, err := policy.ParseRecording(rulesStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HISTORY_RECORDING_RULES: %v", err)
+		}
+		cfg.RecordingRules = rules
+	}
+
+	if intervalStr := os.Getenv("ROLLUP_INTERVAL_SECONDS"); intervalStr != "" {
+		if i, err := strconv.Atoi(intervalStr); err == nil && i >= 1 {
+			cfg.RollupIntervalSec = i
+		} else {
+			return nil, fmt.Errorf("invalid ROLLUP_INTERVAL_SECONDS")
+		}
+	} else {
+		cfg.RollupIntervalSec = 60
+	}
+
+	// Сроки хранения агрегированной истории, 0 — хранить всегда
+	cfg.RollupRetentionDays = map[string]int{"1m": 30, "1h": 730, "1d": 0}
+	for tier, env := range map[string]string{
+		"1m": "ROLLUP_1M_RETENTION_DAYS",
+		"1h": "ROLLUP_1H_RETENTION_DAYS",
+		"1d": "ROLLUP_1D_RETENTION_DAYS",
+	} {
+		if daysStr := os.Getenv(env); daysStr != "" {
+			if d, err := strconv.Atoi(daysStr); err == nil && d >= 0 {
+				cfg.RollupRetentionDays[tier] = d
+			} else {
+				return nil, fmt.Errorf("invalid %s", env)
+			}
+		}
+	}
+
 	if sizeStr := os.Getenv("MQTT_INGEST_QUEUE_SIZE"); sizeStr != "" {
 		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
 			cfg.MQTTIngestQueueSize = size
@@ -132,5 +484,118 @@ func LoadConfig() (*Config, error) {
 		cfg.WorkerCount = 4
 	}
 
+	if sizeStr := os.Getenv("INGEST_BATCH_SIZE"); sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n > 0 {
+			cfg.IngestBatchSize = n
+		} else {
+			return nil, fmt.Errorf("invalid INGEST_BATCH_SIZE")
+		}
+	} else {
+		cfg.IngestBatchSize = 500
+	}
+
+	if intervalStr := os.Getenv("INGEST_BATCH_INTERVAL_MS"); intervalStr != "" {
+		if n, err := strconv.Atoi(intervalStr); err == nil && n > 0 {
+			cfg.IngestBatchIntervalMs = n
+		} else {
+			return nil, fmt.Errorf("invalid INGEST_BATCH_INTERVAL_MS")
+		}
+	} else {
+		cfg.IngestBatchIntervalMs = 200
+	}
+
+	// Очередь на диске включается заданием каталога, иначе очередь в памяти
+	cfg.IngestQueueDir = os.Getenv("INGEST_QUEUE_DIR")
+
+	if bytesStr := os.Getenv("INGEST_QUEUE_MAX_BYTES"); bytesStr != "" {
+		if n, err := strconv.ParseInt(bytesStr, 10, 64); err == nil && n >= 1<<20 {
+			cfg.IngestQueueMaxBytes = n
+		} else {
+			return nil, fmt.Errorf("invalid INGEST_QUEUE_MAX_BYTES: must be at least 1048576")
+		}
+	} else {
+		cfg.IngestQueueMaxBytes = 256 << 20
+	}
+
+	if overflow := os.Getenv("INGEST_QUEUE_OVERFLOW"); overflow != "" {
+		p, err := ingest.ParseOverflowPolicy(overflow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INGEST_QUEUE_OVERFLOW: %v", err)
+		}
+		cfg.IngestQueueOverflow = p
+	} else {
+		cfg.IngestQueueOverflow = ingest.OverflowDropNewest
+	}
+
+	if connsStr := os.Getenv("DB_MAX_OPEN_CONNS"); connsStr != "" {
+		if n, err := strconv.Atoi(connsStr); err == nil && n > 0 {
+			cfg.DBMaxOpenConns = n
+		} else {
+			return nil, fmt.Errorf("invalid DB_MAX_OPEN_CONNS")
+		}
+	} else {
+		cfg.DBMaxOpenConns = 4
+	}
+
+	// Пересылка в InfluxDB включается заданием INFLUX_URL
+	cfg.InfluxURL = os.Getenv("INFLUX_URL")
+	cfg.InfluxToken = os.Getenv("INFLUX_TOKEN")
+
+	cfg.InfluxMeasurement = os.Getenv("INFLUX_MEASUREMENT")
+	if cfg.InfluxMeasurement == "" {
+		cfg.InfluxMeasurement = "brutus"
+	}
+
+	if sizeStr := os.Getenv("INFLUX_BATCH_SIZE"); sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n > 0 {
+			cfg.InfluxBatchSize = n
+		} else {
+			return nil, fmt.Errorf("invalid INFLUX_BATCH_SIZE")
+		}
+	} else {
+		cfg.InfluxBatchSize = 1000
+	}
+
+	if intervalStr := os.Getenv("INFLUX_FLUSH_INTERVAL_MS"); intervalStr != "" {
+		if n, err := strconv.Atoi(intervalStr); err == nil && n > 0 {
+			cfg.InfluxFlushIntervalMs = n
+		} else {
+			return nil, fmt.Errorf("invalid INFLUX_FLUSH_INTERVAL_MS")
+		}
+	} else {
+		cfg.InfluxFlushIntervalMs = 1000
+	}
+
+	cfg.InfluxSpoolFile = os.Getenv("INFLUX_SPOOL_FILE")
+	if cfg.InfluxSpoolFile == "" {
+		cfg.InfluxSpoolFile = "influx.spool"
+	}
+
+	if bytesStr := os.Getenv("INFLUX_SPOOL_MAX_BYTES"); bytesStr != "" {
+		if n, err := strconv.ParseInt(bytesStr, 10, 64); err == nil && n > 0 {
+			cfg.InfluxSpoolMaxBytes = n
+		} else {
+			return nil, fmt.Errorf("invalid INFLUX_SPOOL_MAX_BYTES")
+		}
+	} else {
+		cfg.InfluxSpoolMaxBytes = 64 << 20
+	}
+
+	// Экспорт значений устройств в /metrics включается явно
+	if exportStr := os.Getenv("EXPORT_DEVICE_VALUES"); exportStr != "" {
+		v, err := strconv.ParseBool(exportStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXPORT_DEVICE_VALUES")
+		}
+		cfg.ExportValues = v
+	}
+
+	if cfg.ExportValuesAllow, err = policy.ParsePatterns(os.Getenv("EXPORT_DEVICE_VALUES_ALLOW")); err != nil {
+		return nil, fmt.Errorf("invalid EXPORT_DEVICE_VALUES_ALLOW: %v", err)
+	}
+	if cfg.ExportValuesDeny, err = policy.ParsePatterns(os.Getenv("EXPORT_DEVICE_VALUES_DENY")); err != nil {
+		return nil, fmt.Errorf("invalid EXPORT_DEVICE_VALUES_DENY: %v", err)
+	}
+
 	return cfg, nil
 }
@@ -0,0 +1,79 @@
+// internal/mqttreceiver/exporter/exporter.go
+package exporter
+
+import (
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/policy"
+	"brutus/internal/mqttreceiver/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	valueDesc = prometheus.NewDesc(
+		"mqttreceiver_device_value",
+		"Latest numeric value of a device control.",
+		[]string{"device", "control", "units"}, nil,
+	)
+	updatedDesc = prometheus.NewDesc(
+		"mqttreceiver_device_value_updated_timestamp_seconds",
+		"Unix time of the latest value of a device control.",
+		[]string{"device", "control"}, nil,
+	)
+)
+
+// Collector отдает текущие числовые значения устройств как gauge-метрики.
+// Значения читаются из current_values при каждом опросе Prometheus.
+type Collector struct {
+	db    storage.Store
+	allow []policy.Pattern // пусто — все ряды
+	deny  []policy.Pattern
+}
+
+// NewCollector создает коллектор; deny имеет приоритет над allow
+func NewCollector(db storage.Store, allow, deny []policy.Pattern) *Collector {
+	return &Collector{db: db, allow: allow, deny: deny}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- valueDesc
+	ch <- updatedDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	values, err := c.db.GetCurrentValues()
+	if err != nil {
+		logger.Log.Error().Str("component", "exporter").Err(err).Msg("Failed to read current values")
+		ch <- prometheus.NewInvalidMetric(valueDesc, err)
+		return
+	}
+
+	// Единицы измерения берем из реестра meta
+	units := make(map[string]string)
+	devices, err := c.db.GetDevices("")
+	if err != nil {
+		logger.Log.Warn().Str("component", "exporter").Err(err).Msg("Failed to read device registry")
+	}
+	for _, d := range devices {
+		for _, ctrl := range d.Controls {
+			units[d.Name+"/"+ctrl.Name] = ctrl.Units
+		}
+	}
+
+	for _, v := range values {
+		if v.NumValue == nil || !c.selected(v.Device, v.Parameter) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(valueDesc, prometheus.GaugeValue, *v.NumValue,
+			v.Device, v.Parameter, units[v.Device+"/"+v.Parameter])
+		ch <- prometheus.MustNewConstMetric(updatedDesc, prometheus.GaugeValue,
+			float64(v.UpdatedAt.UnixMilli())/1000, v.Device, v.Parameter)
+	}
+}
+
+func (c *Collector) selected(device, parameter string) bool {
+	if len(c.allow) > 0 && !policy.MatchAny(c.allow, device, parameter) {
+		return false
+	}
+	return !policy.MatchAny(c.deny, device, parameter)
+}
@@ -4,11 +4,14 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"time"
 
+	"brutus/internal/mqttreceiver/auth"
 	"brutus/internal/mqttreceiver/logger"
 	"brutus/internal/mqttreceiver/metrics"
 	"brutus/internal/mqttreceiver/mqtt"
@@ -16,31 +19,50 @@ import (
 	pb "brutus/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// Options — настройки gRPC сервера: рассылка значений клиентам DataExchange и доступ
+type Options struct {
+	ReplayBuffer        int                 // сколько последних значений хранить для возобновления по номеру
+	SubscriberBuffer    int                 // размер очереди клиента по умолчанию
+	MaxSubscriberBuffer int                 // верхняя граница размера очереди, запрошенного клиентом
+	SlowConsumerPolicy  SlowConsumerPolicy  // политика по умолчанию при заполненной очереди
+	Auth                *auth.Authenticator // nil — доступ без аутентификации
+	TLS                 *tls.Config         // nil — соединения без TLS
+}
+
 type Server struct {
 	pb.UnimplementedMQTTReceiverServer
-	mqttClient  *mqtt.Client
-	db          *storage.DB
-	mu          sync.Mutex
-	subscribers map[chan *pb.Value]struct{}
-}
+	opts        Options
+	mqttRouter  *mqtt.Router
+	db          storage.Store
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	grpcServer  *grpc.Server
+	stopped     bool
 
-// SetMQTTClient устанавливает MQTT клиента после инициализации
-func (s *Server) SetMQTTClient(client *mqtt.Client) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.mqttClient = client
+	// fanout упорядочивает рассылку: номер значения, буфер и очереди клиентов
+	// меняются под ним, поэтому каждый клиент получает значения по возрастанию номеров
+	fanout sync.Mutex
+	seq    uint64
+	ring   *valueRing
 }
 
-// NewServer создает новый экземпляр gRPC сервера
-func NewServer(db *storage.DB, mqttClient *mqtt.Client) *Server {
+// NewServer создает новый экземпляр gRPC сервера; команды уходят брокерам через mqttRouter
+func NewServer(db storage.Store, mqttRouter *mqtt.Router, opts Options) *Server {
 	return &Server{
-		mqttClient:  mqttClient,
+		opts:        opts,
+		mqttRouter:  mqttRouter,
 		db:          db,
-		subscribers: make(map[chan *pb.Value]struct{}),
+		subscribers: make(map[*subscriber]struct{}),
+		// Номера продолжают расти и после перезапуска сервиса
+		seq:  uint64(time.Now().UnixMicro()),
+		ring: newValueRing(opts.ReplayBuffer),
 	}
 }
 
@@ -59,37 +81,63 @@ func (s *Server) DataExchange(stream pb.MQTTReceiver_DataExchangeServer) error {
 			Msg("Client connected to DataExchange (IP unknown)")
 	}
 
-	ch := make(chan *pb.Value, 100)
+	sub := newSubscriber(auth.FromContext(stream.Context()), s.opts.SlowConsumerPolicy, s.opts.SubscriberBuffer)
 
 	s.mu.Lock()
-	s.subscribers[ch] = struct{}{}
+	s.subscribers[sub] = struct{}{}
 	s.mu.Unlock()
 
 	defer func() {
 		s.mu.Lock()
-		delete(s.subscribers, ch)
-		close(ch)
+		delete(s.subscribers, sub)
 		s.mu.Unlock()
+		sub.close()
 
 		logger.Log.Info().
 			Str("component", "grpc").
 			Msg("Client disconnected from DataExchange")
 	}()
 
-	// Поток отправки данных клиенту
+	// Поток получения команд от клиента
+	received := make(chan error, 1)
 	go func() {
-		for val := range ch {
+		received <- s.receive(stream, sub)
+	}()
+
+	// Отправка данных клиенту из его очереди
+	for {
+		select {
+		case err := <-received:
+			return err
+		case <-sub.notify:
+		}
+
+		values, overflowed := sub.take()
+		if overflowed {
+			metrics.SlowConsumerDisconnects.Inc()
+			logger.Log.Warn().
+				Str("component", "grpc").
+				Msg("Disconnecting slow DataExchange client")
+			return status.Error(codes.ResourceExhausted, "client is too slow, subscriber queue overflowed")
+		}
+		for _, val := range values {
 			if err := stream.Send(val); err != nil {
 				logger.Log.Error().
 					Str("component", "grpc").
 					Err(err).
 					Msg("Failed to send value to client")
-				return
+				return err
 			}
 		}
-	}()
+	}
+}
 
-	// Поток получения команд от клиента
+// Больше команд одного потока одновременно не выполняем: каждая ждет подтверждения от брокера
+const maxInflightCommands = 16
+
+// receive принимает от клиента подписки и команды до конца потока
+func (s *Server) receive(stream pb.MQTTReceiver_DataExchangeServer, sub *subscriber) error {
+	inflight := make(chan struct{}, maxInflightCommands)
 	for {
 		cmd, err := stream.Recv()
 		if err == io.EOF {
@@ -103,6 +151,14 @@ func (s *Server) DataExchange(stream pb.MQTTReceiver_DataExchangeServer) error {
 			return err
 		}
 
+		// Сообщение с подпиской меняет набор рядов, которые получает клиент
+		if cmd.Subscription != nil {
+			if err := s.subscribe(sub, cmd.Subscription); err != nil {
+				return err
+			}
+			continue
+		}
+
 		logger.Log.Info().
 			Str("component", "grpc").
 			Str("device", cmd.Device).
@@ -110,11 +166,123 @@ func (s *Server) DataExchange(stream pb.MQTTReceiver_DataExchangeServer) error {
 			Str("value", cmd.Value).
 			Msg("Command received from gRPC client")
 
-		s.mqttClient.Publish(cmd.Device, cmd.Parameter, cmd.Value)
+		// Команду выполняем асинхронно: ожидание подтверждения не должно блокировать прием.
+		// Сверх лимита команды сразу отклоняются, а не копятся горутинами.
+		select {
+		case inflight <- struct{}{}:
+			go func() {
+				defer func() { <-inflight }()
+				s.executeCommand(sub, cmd)
+			}()
+		default:
+			s.replyCommand(sub, cmd, &pb.CommandResult{
+				RequestId: cmd.RequestId,
+				Status:    pb.CommandStatus_COMMAND_STATUS_REJECTED,
+				Reason:    "too many commands in flight",
+			})
+		}
 	}
 }
 
-// BroadcastValue отправляет значение всем подписчикам
+// executeCommand проверяет readonly, отправляет команду и возвращает клиенту результат
+func (s *Server) executeCommand(sub *subscriber, cmd *pb.Command) {
+	result := &pb.CommandResult{RequestId: cmd.RequestId}
+
+	ctrl, err := s.db.GetControl(cmd.Device, cmd.Parameter)
+	switch {
+	case !sub.identity.Can(auth.PermCommand, cmd.Device, cmd.Parameter):
+		result.Status = pb.CommandStatus_COMMAND_STATUS_REJECTED
+		result.Reason = "permission denied"
+	case err != nil:
+		logger.Log.Error().
+			Str("component", "grpc").
+			Err(err).
+			Msg("Failed to load control meta")
+		result.Status = pb.CommandStatus_COMMAND_STATUS_REJECTED
+		result.Reason = "failed to load control meta"
+	case ctrl != nil && ctrl.Readonly:
+		result.Status = pb.CommandStatus_COMMAND_STATUS_REJECTED
+		result.Reason = "control is readonly"
+	default:
+		res := s.mqttRouter.SendCommand(cmd.Device, cmd.Parameter, cmd.Value)
+		result.Reason = res.Reason
+		switch res.Status {
+		case mqtt.CommandApplied:
+			result.Status = pb.CommandStatus_COMMAND_STATUS_APPLIED
+		case mqtt.CommandTimedOut:
+			result.Status = pb.CommandStatus_COMMAND_STATUS_TIMED_OUT
+		default:
+			result.Status = pb.CommandStatus_COMMAND_STATUS_REJECTED
+		}
+	}
+
+	s.replyCommand(sub, cmd, result)
+}
+
+// replyCommand отправляет клиенту результат команды
+func (s *Server) replyCommand(sub *subscriber, cmd *pb.Command, result *pb.CommandResult) {
+	if result.Status == pb.CommandStatus_COMMAND_STATUS_REJECTED {
+		logger.Log.Warn().
+			Str("component", "grpc").
+			Str("device", cmd.Device).
+			Str("param", cmd.Parameter).
+			Str("reason", result.Reason).
+			Msg("Command rejected")
+	}
+
+	msg := &pb.Value{
+		Device:    cmd.Device,
+		Parameter: cmd.Parameter,
+		Value:     cmd.Value,
+		Timestamp: time.Now().UnixMilli(),
+		Result:    result,
+	}
+
+	// Ответ на команду не отбрасывается политикой; если клиент уже отключился, он игнорируется
+	sub.push(msg)
+}
+
+// currentValues возвращает текущие значения рядов, подходящих под фильтры и доступных клиенту
+func (s *Server) currentValues(id *auth.Identity, filters []string) ([]*pb.Value, error) {
+	current, err := s.db.GetCurrentValues()
+	if err != nil {
+		logger.Log.Error().
+			Str("component", "grpc").
+			Err(err).
+			Msg("Failed to get current values")
+		return nil, status.Error(codes.Internal, "failed to get current values")
+	}
+
+	values := make([]*pb.Value, 0, len(current))
+	for _, v := range current {
+		if !matchFilters(filters, v.Device, v.Parameter) || !id.Can(auth.PermSubscribe, v.Device, v.Parameter) {
+			continue
+		}
+		values = append(values, &pb.Value{
+			Device:    v.Device,
+			Parameter: v.Parameter,
+			Value:     v.Value,
+			Timestamp: v.UpdatedAt.UnixMilli(),
+			Snapshot:  true,
+		})
+	}
+	return values, nil
+}
+
+// GetCurrentValues возвращает текущие значения рядов вместе со временем их обновления
+func (s *Server) GetCurrentValues(ctx context.Context, req *pb.CurrentValuesRequest) (*pb.CurrentValuesResponse, error) {
+	if err := validateFilters(req.Filters); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	values, err := s.currentValues(auth.FromContext(ctx), req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CurrentValuesResponse{Values: values}, nil
+}
+
+// BroadcastValue отправляет значение подписчикам, чьи фильтры подходят под ряд
 func (s *Server) BroadcastValue(device, parameter, value string, timestamp int64) {
 	msg := &pb.Value{
 		Device:    device,
@@ -123,51 +291,156 @@ func (s *Server) BroadcastValue(device, parameter, value string, timestamp int64
 		Timestamp: timestamp,
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.fanout.Lock()
+	defer s.fanout.Unlock()
 
+	s.seq++
+	msg.Sequence = s.seq
+	s.ring.push(msg)
+
+	// Постановка в очередь клиента не ждет его, поэтому медленный клиент не держит замок
 	dropped := 0
-	for ch := range s.subscribers {
-		select {
-		case ch <- msg:
-			// отправлено успешно
-		default:
+	s.mu.RLock()
+	for sub := range s.subscribers {
+		if !sub.enqueue(msg) {
 			dropped++
 		}
 	}
+	s.mu.RUnlock()
 
 	if dropped > 0 {
 		logger.Log.Warn().
 			Str("component", "grpc").
 			Int("dropped", dropped).
-			Msg("BroadcastValue: some subscriber channels full, dropped messages")
+			Msg("BroadcastValue: some subscriber queues full, dropped messages")
 		metrics.BroadcastDropped.Add(float64(dropped))
 	}
 }
 
-// GetHistory реализует получение истории значений по параметру и периоду
-func (s *Server) GetHistory(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
-	// Вызываем метод storage.GetHistory с Unix миллисекундами
-	history, err := s.db.GetHistory(req.Device, req.Parameter, req.StartTimestamp, req.EndTimestamp)
+// GetAggregatedHistory возвращает историю, прореженную до корзин заданного размера
+func (s *Server) GetAggregatedHistory(ctx context.Context, req *pb.AggregatedHistoryRequest) (*pb.AggregatedHistoryResponse, error) {
+	if !auth.FromContext(ctx).Can(auth.PermReadHistory, req.Device, req.Parameter) {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	if req.BucketMs <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "bucket_ms must be positive")
+	}
+	bucket := time.Duration(req.BucketMs) * time.Millisecond
+	if err := storage.ValidateAggregateRange(req.StartTimestamp, req.EndTimestamp, bucket); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	aggregates := req.Aggregates
+	if len(aggregates) == 0 {
+		aggregates = []pb.Aggregate{pb.Aggregate_AGGREGATE_AVG}
+	}
+
+	buckets, err := s.db.GetAggregatedHistory(req.Device, req.Parameter, req.StartTimestamp, req.EndTimestamp, bucket)
 	if err != nil {
 		logger.Log.Error().
 			Str("component", "grpc").
 			Err(err).
-			Msg("Failed to get history")
-		return nil, err
+			Msg("Failed to get aggregated history")
+		return nil, status.Error(codes.Internal, "failed to get aggregated history")
 	}
 
-	values := make([]*pb.Value, 0, len(history))
-	for _, h := range history {
-		values = append(values, &pb.Value{
-			Device:    h.Device,
-			Parameter: h.Parameter,
-			Value:     h.Value,
-			Timestamp: h.Timestamp.UnixMilli(), // преобразуем time.Time в Unix миллисекунды
-		})
+	points := make([]*pb.AggregatedPoint, 0, len(buckets))
+	for i := range buckets {
+		points = append(points, aggregatedPoint(&buckets[i], aggregates))
+	}
+
+	return &pb.AggregatedHistoryResponse{Points: points}, nil
+}
+
+// Агрегаты API и соответствующие им агрегаты хранилища
+var storageAggregates = map[pb.Aggregate]storage.Aggregate{
+	pb.Aggregate_AGGREGATE_MIN:   storage.AggregateMin,
+	pb.Aggregate_AGGREGATE_MAX:   storage.AggregateMax,
+	pb.Aggregate_AGGREGATE_AVG:   storage.AggregateAvg,
+	pb.Aggregate_AGGREGATE_FIRST: storage.AggregateFirst,
+	pb.Aggregate_AGGREGATE_LAST:  storage.AggregateLast,
+	pb.Aggregate_AGGREGATE_COUNT: storage.AggregateCount,
+	pb.Aggregate_AGGREGATE_SUM:   storage.AggregateSum,
+}
+
+// aggregatedPoint заполняет только запрошенные агрегаты корзины
+func aggregatedPoint(b *storage.Bucket, aggregates []pb.Aggregate) *pb.AggregatedPoint {
+	p := &pb.AggregatedPoint{Timestamp: b.Start.UnixMilli()}
+	value := func(fn storage.Aggregate) *float64 {
+		if v, ok := b.Value(fn); ok {
+			return &v
+		}
+		return nil
+	}
+
+	for _, agg := range aggregates {
+		switch agg {
+		case pb.Aggregate_AGGREGATE_MIN:
+			p.Min = value(storage.AggregateMin)
+		case pb.Aggregate_AGGREGATE_MAX:
+			p.Max = value(storage.AggregateMax)
+		case pb.Aggregate_AGGREGATE_AVG:
+			p.Avg = value(storage.AggregateAvg)
+		case pb.Aggregate_AGGREGATE_FIRST:
+			p.First = value(storage.AggregateFirst)
+		case pb.Aggregate_AGGREGATE_LAST:
+			p.Last = value(storage.AggregateLast)
+		case pb.Aggregate_AGGREGATE_COUNT:
+			count := b.Count
+			p.Count = &count
+		case pb.Aggregate_AGGREGATE_SUM:
+			p.Sum = value(storage.AggregateSum)
+		}
+	}
+	return p
+}
+
+// GetDevices возвращает реестр устройств и контролов, собранный из meta-топиков
+func (s *Server) GetDevices(ctx context.Context, req *pb.DevicesRequest) (*pb.DevicesResponse, error) {
+	devices, err := s.db.GetDevices(req.Device)
+	if err != nil {
+		logger.Log.Error().
+			Str("component", "grpc").
+			Err(err).
+			Msg("Failed to get devices")
+		return nil, status.Error(codes.Internal, "failed to get devices")
+	}
+
+	id := auth.FromContext(ctx)
+	resp := &pb.DevicesResponse{Devices: make([]*pb.DeviceInfo, 0, len(devices))}
+	for _, d := range devices {
+		info := &pb.DeviceInfo{
+			Id:        d.Name,
+			Title:     d.Title,
+			Driver:    d.Driver,
+			Error:     d.Error,
+			UpdatedAt: d.UpdatedAt.UnixMilli(),
+			Controls:  make([]*pb.ControlInfo, 0, len(d.Controls)),
+		}
+		for _, c := range d.Controls {
+			if !id.CanSee(d.Name, c.Name) {
+				continue
+			}
+			info.Controls = append(info.Controls, &pb.ControlInfo{
+				Id:        c.Name,
+				Title:     c.Title,
+				Type:      c.Type,
+				Units:     c.Units,
+				Readonly:  c.Readonly,
+				Order:     int32(c.Order),
+				Min:       c.Min,
+				Max:       c.Max,
+				Error:     c.Error,
+				UpdatedAt: c.UpdatedAt.UnixMilli(),
+			})
+		}
+		// Устройство без доступных контролов клиенту не показываем
+		if id != nil && len(info.Controls) == 0 {
+			continue
+		}
+		resp.Devices = append(resp.Devices, info)
 	}
 
-	return &pb.HistoryResponse{Values: values}, nil
+	return resp, nil
 }
 
 // Start запускает gRPC сервер
@@ -176,14 +449,45 @@ func (s *Server) Start(port int) error {
 	if err != nil {
 		return err
 	}
-	grpcServer := grpc.NewServer()
+	var opts []grpc.ServerOption
+	if s.opts.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.opts.TLS)))
+	}
+	if s.opts.Auth != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(s.opts.Auth.UnaryInterceptor),
+			grpc.ChainStreamInterceptor(s.opts.Auth.StreamInterceptor),
+		)
+	}
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterMQTTReceiverServer(grpcServer, s)
 	reflection.Register(grpcServer)
 
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return lis.Close()
+	}
+	s.grpcServer = grpcServer
+	s.mu.Unlock()
+
 	logger.Log.Info().
 		Str("component", "grpc").
 		Int("port", port).
+		Bool("tls", s.opts.TLS != nil).
 		Msg("gRPC server listening")
 
 	return grpcServer.Serve(lis)
 }
+
+// Stop закрывает соединения клиентов; подписки обрываются, Start возвращает nil
+func (s *Server) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	grpcServer := s.grpcServer
+	s.mu.Unlock()
+
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+}
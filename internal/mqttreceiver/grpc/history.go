@@ -0,0 +1,321 @@
+// internal/mqttreceiver/grpc/history.go
+
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"brutus/internal/mqttreceiver/auth"
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/storage"
+	pb "brutus/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Сколько строк истории отправляется в одной части StreamHistory
+const historyChunkSize = 1000
+
+// GetHistory реализует получение истории значений по параметру и периоду.
+// С limit история отдается страницами, следующая запрашивается по next_page_token.
+func (s *Server) GetHistory(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	if !auth.FromContext(ctx).Can(auth.PermReadHistory, req.Device, req.Parameter) {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	q, err := historyQuery(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Строка сверх страницы показывает, что есть следующая
+	if q.Limit > 0 {
+		q.Limit++
+	}
+	history, err := s.db.QueryHistory(q)
+	if err != nil {
+		logger.Log.Error().
+			Str("component", "grpc").
+			Err(err).
+			Msg("Failed to get history")
+		return nil, status.Error(codes.Internal, "failed to get history")
+	}
+
+	resp := &pb.HistoryResponse{}
+	if req.Limit > 0 && len(history) > int(req.Limit) {
+		history = history[:req.Limit]
+		resp.NextPageToken = encodePageToken(history[len(history)-1])
+	}
+	resp.Values = historyValues(history)
+	return resp, nil
+}
+
+// StreamHistory отправляет историю частями, читая ее курсором БД
+func (s *Server) StreamHistory(req *pb.HistoryRequest, stream pb.MQTTReceiver_StreamHistoryServer) error {
+	if !auth.FromContext(stream.Context()).Can(auth.PermReadHistory, req.Device, req.Parameter) {
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	q, err := historyQuery(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Ошибки отправки и отмена клиентом отдаются как есть, ошибки хранилища — как Internal
+	ctx := stream.Context()
+	var sendErr error
+	err = s.db.StreamHistory(q, historyChunkSize, func(chunk []storage.History) error {
+		if err := ctx.Err(); err != nil {
+			sendErr = status.FromContextError(err).Err()
+			return sendErr
+		}
+		// Позиция последней строки позволяет продолжить выгрузку после обрыва
+		sendErr = stream.Send(&pb.HistoryResponse{
+			Values:        historyValues(chunk),
+			NextPageToken: encodePageToken(chunk[len(chunk)-1]),
+		})
+		return sendErr
+	})
+	switch {
+	case err == nil:
+		return nil
+	case sendErr != nil:
+		return sendErr
+	}
+	logger.Log.Error().
+		Str("component", "grpc").
+		Err(err).
+		Msg("Failed to stream history")
+	return status.Error(codes.Internal, "failed to stream history")
+}
+
+// historyQuery переводит запрос в выборку хранилища
+func historyQuery(req *pb.HistoryRequest) (storage.HistoryQuery, error) {
+	if req.Limit < 0 {
+		return storage.HistoryQuery{}, fmt.Errorf("limit must not be negative")
+	}
+	conds, err := storage.ParseValueFilter(req.ValueFilter)
+	if err != nil {
+		return storage.HistoryQuery{}, err
+	}
+
+	q := storage.HistoryQuery{
+		Device:     req.Device,
+		Parameter:  req.Parameter,
+		StartMs:    req.StartTimestamp,
+		EndMs:      req.EndTimestamp,
+		Conds:      conds,
+		Limit:      int(req.Limit),
+		Descending: req.Descending,
+	}
+	if req.PageToken != "" {
+		cursor, err := decodePageToken(req.PageToken)
+		if err != nil {
+			return storage.HistoryQuery{}, err
+		}
+		q.After = cursor
+	}
+	return q, nil
+}
+
+func historyValues(history []storage.History) []*pb.Value {
+	values := make([]*pb.Value, 0, len(history))
+	for _, h := range history {
+		values = append(values, &pb.Value{
+			Device:    h.Device,
+			Parameter: h.Parameter,
+			Value:     h.Value,
+			Timestamp: h.Timestamp.UnixMilli(), // преобразуем time.Time в Unix миллисекунды
+		})
+	}
+	return values
+}
+
+// Токен страницы — позиция последней отданной строки: "<timestamp ms>.<id>" в base64
+func encodePageToken(h storage.History) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(strconv.FormatInt(h.Timestamp.UnixMilli(), 10) + "." + strconv.FormatUint(uint64(h.ID), 10)))
+}
+
+func decodePageToken(token string) (*storage.HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page_token")
+	}
+	tsStr, idStr, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid page_token")
+	}
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page_token")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page_token")
+	}
+	return &storage.HistoryCursor{Timestamp: time.UnixMilli(ts).UTC(), ID: uint(id)}, nil
+}
+
+const (
+	// Больше рядов в одном запросе GetMultiHistory не отдаем
+	maxMultiHistorySeries = 100
+	// Без выравнивания не больше строк на ряд; используется и при limit_per_series = 0
+	maxLimitPerSeries = 10000
+)
+
+// series — ключ ряда device/parameter
+type series struct {
+	device    string
+	parameter string
+}
+
+// GetMultiHistory возвращает историю нескольких рядов за общий период.
+// С align_ms значения всех рядов сводятся в корзины с общими границами.
+func (s *Server) GetMultiHistory(ctx context.Context, req *pb.MultiHistoryRequest) (*pb.MultiHistoryResponse, error) {
+	if len(req.Series) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "series must not be empty")
+	}
+	if err := validateFilters(req.Series); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.AlignMs < 0 || req.LimitPerSeries < 0 {
+		return nil, status.Error(codes.InvalidArgument, "align_ms and limit_per_series must not be negative")
+	}
+	if req.LimitPerSeries > maxLimitPerSeries {
+		return nil, status.Errorf(codes.InvalidArgument, "limit_per_series must be at most %d", maxLimitPerSeries)
+	}
+	limit := int(req.LimitPerSeries)
+	if limit == 0 {
+		limit = maxLimitPerSeries
+	}
+	align := time.Duration(req.AlignMs) * time.Millisecond
+	if req.AlignMs > 0 {
+		if err := storage.ValidateAggregateRange(req.StartTimestamp, req.EndTimestamp, align); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	aggregate := storage.AggregateAvg
+	if req.Aggregate != pb.Aggregate_AGGREGATE_UNSPECIFIED {
+		fn, ok := storageAggregates[req.Aggregate]
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, "unknown aggregate")
+		}
+		aggregate = fn
+	}
+
+	list, err := s.expandSeries(auth.FromContext(ctx), req.Series)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > maxMultiHistorySeries {
+		return nil, status.Errorf(codes.InvalidArgument, "too many series: %d, at most %d", len(list), maxMultiHistorySeries)
+	}
+
+	resp := &pb.MultiHistoryResponse{Series: make([]*pb.SeriesHistory, 0, len(list))}
+	for _, sr := range list {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		out := &pb.SeriesHistory{Device: sr.device, Parameter: sr.parameter}
+		if req.AlignMs > 0 {
+			buckets, err := s.db.GetAggregatedHistory(sr.device, sr.parameter, req.StartTimestamp, req.EndTimestamp, align)
+			if err != nil {
+				logger.Log.Error().
+					Str("component", "grpc").
+					Err(err).
+					Msg("Failed to get aggregated history")
+				return nil, status.Error(codes.Internal, "failed to get aggregated history")
+			}
+			for i := range buckets {
+				v, ok := buckets[i].Value(aggregate)
+				if !ok {
+					continue
+				}
+				out.Values = append(out.Values, &pb.Value{
+					Device:    sr.device,
+					Parameter: sr.parameter,
+					Value:     strconv.FormatFloat(v, 'g', -1, 64),
+					Timestamp: buckets[i].Start.UnixMilli(),
+				})
+			}
+		} else {
+			history, err := s.db.QueryHistory(storage.HistoryQuery{
+				Device:    sr.device,
+				Parameter: sr.parameter,
+				StartMs:   req.StartTimestamp,
+				EndMs:     req.EndTimestamp,
+				Limit:     limit,
+			})
+			if err != nil {
+				logger.Log.Error().
+					Str("component", "grpc").
+					Err(err).
+					Msg("Failed to get history")
+				return nil, status.Error(codes.Internal, "failed to get history")
+			}
+			out.Values = historyValues(history)
+		}
+		resp.Series = append(resp.Series, out)
+	}
+	return resp, nil
+}
+
+// expandSeries раскрывает маски по известным рядам; ряды без масок берутся как есть.
+// Недоступный явно заданный ряд — ошибка, недоступные ряды по маскам пропускаются.
+func (s *Server) expandSeries(id *auth.Identity, filters []string) ([]series, error) {
+	seen := make(map[series]bool)
+	var list []series
+	add := func(sr series) {
+		if !seen[sr] {
+			seen[sr] = true
+			list = append(list, sr)
+		}
+	}
+
+	var patterns []string
+	for _, f := range filters {
+		if strings.ContainsAny(f, "+#") {
+			patterns = append(patterns, f)
+			continue
+		}
+		device, parameter, ok := strings.Cut(f, "/")
+		if !ok || device == "" || parameter == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "series %q: expected device/parameter", f)
+		}
+		if !id.Can(auth.PermReadHistory, device, parameter) {
+			return nil, status.Errorf(codes.PermissionDenied, "series %q: permission denied", f)
+		}
+		add(series{device: device, parameter: parameter})
+	}
+
+	if len(patterns) > 0 {
+		current, err := s.db.GetCurrentValues()
+		if err != nil {
+			logger.Log.Error().
+				Str("component", "grpc").
+				Err(err).
+				Msg("Failed to get current values")
+			return nil, status.Error(codes.Internal, "failed to list series")
+		}
+		for _, v := range current {
+			if matchFilters(patterns, v.Device, v.Parameter) && id.Can(auth.PermReadHistory, v.Device, v.Parameter) {
+				add(series{device: v.Device, parameter: v.Parameter})
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].device != list[j].device {
+			return list[i].device < list[j].device
+		}
+		return list[i].parameter < list[j].parameter
+	})
+	return list, nil
+}
@@ -0,0 +1,253 @@
+// internal/mqttreceiver/grpc/replay.go
+
+package grpc
+
+import (
+	"strings"
+	"time"
+
+	"brutus/internal/mqttreceiver/auth"
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/metrics"
+	"brutus/internal/mqttreceiver/storage"
+	pb "brutus/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Сколько живых значений копится у клиента, пока ему досылается снимок или повтор
+	replayBacklogLimit = 10000
+	// Больше строк из истории при возобновлении не повторяем — для этого есть GetHistory
+	replayHistoryLimit = 100000
+)
+
+// valueRing — кольцевой буфер последних живых значений для возобновления потока
+type valueRing struct {
+	buf   []*pb.Value
+	start int
+	n     int
+}
+
+func newValueRing(size int) *valueRing {
+	return &valueRing{buf: make([]*pb.Value, size)}
+}
+
+func (r *valueRing) push(v *pb.Value) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *valueRing) at(i int) *pb.Value {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+// oldest возвращает самое старое значение в буфере или nil
+func (r *valueRing) oldest() *pb.Value {
+	if r.n == 0 {
+		return nil
+	}
+	return r.at(0)
+}
+
+// after возвращает значения с номером больше seq
+func (r *valueRing) after(seq uint64) []*pb.Value {
+	var values []*pb.Value
+	for i := 0; i < r.n; i++ {
+		if v := r.at(i); v.Sequence > seq {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// subscribe меняет подписку клиента и досылает снимок или пропущенные значения.
+// Пока они ставятся в очередь, живые значения копятся в backlog клиента, поэтому
+// переход к живому потоку происходит без пропусков и без нарушения порядка.
+func (s *Server) subscribe(sub *subscriber, req *pb.Subscription) error {
+	if err := validateFilters(req.Filters); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	resume := req.SinceSequence > 0 || req.SinceTimestamp > 0
+	replaying := resume || req.Snapshot
+
+	limit := s.opts.SubscriberBuffer
+	if req.BufferSize > 0 {
+		limit = min(int(req.BufferSize), s.opts.MaxSubscriberBuffer)
+	}
+	policy := slowConsumerPolicyFromProto(req.SlowConsumerPolicy, s.opts.SlowConsumerPolicy)
+
+	// Источник повтора выбираем под тем же замком, под которым рассылаются живые значения
+	s.fanout.Lock()
+	var missed []*pb.Value
+	var historyFrom, historyTo int64
+	switch {
+	case req.SinceSequence > 0 && req.SinceSequence >= s.seq:
+		// Клиент ничего не пропустил
+	case req.SinceSequence > 0 && s.ring.oldest() != nil && req.SinceSequence+1 >= s.ring.oldest().Sequence:
+		missed = s.ring.after(req.SinceSequence)
+	case req.SinceTimestamp > 0:
+		historyFrom, historyTo = req.SinceTimestamp, time.Now().UnixMilli()
+		if oldest := s.ring.oldest(); oldest != nil {
+			historyTo = oldest.Timestamp
+			for _, v := range s.ring.after(0) {
+				if v.Timestamp > req.SinceTimestamp {
+					missed = append(missed, v)
+				}
+			}
+		}
+	case req.SinceSequence > 0:
+		s.fanout.Unlock()
+		return status.Error(codes.OutOfRange, "since_sequence is no longer buffered, resume with since_timestamp")
+	}
+	sub.configure(req.Filters, policy, limit)
+	if replaying {
+		sub.startReplay()
+	}
+	s.fanout.Unlock()
+
+	logger.Log.Info().
+		Str("component", "grpc").
+		Strs("filters", req.Filters).
+		Bool("snapshot", req.Snapshot).
+		Uint64("since_sequence", req.SinceSequence).
+		Int64("since_timestamp", req.SinceTimestamp).
+		Str("slow_consumer_policy", string(policy)).
+		Int("buffer_size", limit).
+		Msg("Client subscription updated")
+
+	if !replaying {
+		return nil
+	}
+
+	// Снимок текущих значений имеет смысл, только если клиент не продолжает прежний поток
+	if req.Snapshot && !resume {
+		values, err := s.currentValues(sub.identity, req.Filters)
+		if err != nil {
+			return err
+		}
+		sub.replay(values)
+	}
+
+	if historyFrom > 0 && historyFrom < historyTo {
+		values, err := s.replayHistory(sub, req.Filters, historyFrom, historyTo, missed)
+		if err != nil {
+			return err
+		}
+		sub.replay(values)
+	}
+
+	var replayed []*pb.Value
+	for _, v := range missed {
+		if matchFilters(req.Filters, v.Device, v.Parameter) && sub.allowed(v.Device, v.Parameter) {
+			replayed = append(replayed, v)
+		}
+	}
+	sub.replay(replayed)
+
+	// Накопленное за время повтора идет следом, дальше — живой поток
+	sub.finishReplay()
+	return nil
+}
+
+// replayHistory читает из истории значения рядов, подходящих под фильтры, за период (from, to].
+// Строки с временем to, которые есть и в буфере (buffered), пропускаются. Номера у значений
+// нет: в истории есть и строки, которые этот сервер не рассылал (например, записанные
+// другими репликами кластера), поэтому номер живого значения им дать нельзя.
+func (s *Server) replayHistory(sub *subscriber, filters []string, from, to int64, buffered []*pb.Value) ([]*pb.Value, error) {
+	series, all := historySeries(filters)
+	if !all && len(series) == 0 {
+		return nil, nil
+	}
+	history, err := s.db.GetHistoryRange(series, from, to, replayHistoryLimit+1)
+	if err != nil {
+		logger.Log.Error().
+			Str("component", "grpc").
+			Err(err).
+			Msg("Failed to read history for replay")
+		return nil, status.Error(codes.Internal, "failed to read history for replay")
+	}
+	if len(history) > replayHistoryLimit {
+		return nil, status.Error(codes.OutOfRange, "too many values to replay, use GetHistory")
+	}
+
+	// Значения буфера в ту же миллисекунду, что и конец периода, уже будут повторены из буфера
+	inBuffer := make(map[string]int)
+	for _, v := range buffered {
+		if v.Timestamp == to {
+			inBuffer[v.Device+"/"+v.Parameter+"="+v.Value]++
+		}
+	}
+
+	values := make([]*pb.Value, 0, len(history))
+	for _, h := range history {
+		if !matchFilters(filters, h.Device, h.Parameter) || !sub.allowed(h.Device, h.Parameter) ||
+			!sub.identity.Can(auth.PermReadHistory, h.Device, h.Parameter) {
+			continue
+		}
+		ts := h.Timestamp.UnixMilli()
+		if key := h.Device + "/" + h.Parameter + "=" + h.Value; ts == to && inBuffer[key] > 0 {
+			inBuffer[key]--
+			continue
+		}
+		values = append(values, &pb.Value{
+			Device:    h.Device,
+			Parameter: h.Parameter,
+			Value:     h.Value,
+			Timestamp: ts,
+		})
+	}
+	return values, nil
+}
+
+// historySeries переводит фильтры "device/parameter" в условия выборки истории, чтобы
+// не читать все ряды за период. all — фильтры (или один из них) подходят под все ряды;
+// точная проверка по фильтрам все равно выполняется после чтения.
+func historySeries(filters []string) (series []storage.SeriesFilter, all bool) {
+	if len(filters) == 0 {
+		return nil, true
+	}
+	for _, f := range filters {
+		levels := strings.Split(f, "/")
+		var sf storage.SeriesFilter
+		switch {
+		case len(levels) == 1 && levels[0] == "#":
+		case len(levels) == 2 && levels[1] == "#":
+			sf.Device = levels[0]
+		case len(levels) == 2, len(levels) == 3 && levels[2] == "#":
+			// "#" в конце подходит и под сам уровень выше: "device/parameter/#"
+			sf.Device, sf.Parameter = levels[0], levels[1]
+		default:
+			// Ряд "device/parameter" под такой фильтр не подходит
+			continue
+		}
+		if sf.Device == "+" {
+			sf.Device = ""
+		}
+		if sf.Parameter == "+" {
+			sf.Parameter = ""
+		}
+		if sf.Device == "" && sf.Parameter == "" {
+			return nil, true
+		}
+		series = append(series, sf)
+	}
+	return series, false
+}
+
+// replay ставит в очередь клиента снимок или пропущенные значения
+func (sub *subscriber) replay(values []*pb.Value) {
+	sub.push(values...)
+	if len(values) > 0 {
+		metrics.ReplayedValues.Add(float64(len(values)))
+	}
+}
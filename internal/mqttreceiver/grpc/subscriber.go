@@ -0,0 +1,250 @@
+// internal/mqttreceiver/grpc/subscriber.go
+
+package grpc
+
+import (
+	"fmt"
+	"sync"
+
+	"brutus/internal/mqttreceiver/auth"
+	"brutus/internal/mqttreceiver/topic"
+	pb "brutus/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// SlowConsumerPolicy — что делать с живым значением, когда очередь клиента заполнена
+type SlowConsumerPolicy string
+
+const (
+	SlowConsumerDropNewest SlowConsumerPolicy = "drop_newest" // выбросить входящее значение
+	SlowConsumerDropOldest SlowConsumerPolicy = "drop_oldest" // выбросить самое старое значение в очереди
+	SlowConsumerCoalesce   SlowConsumerPolicy = "coalesce"    // заменить ждущее значение того же ряда последним
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect"  // отключить клиента
+)
+
+// ParseSlowConsumerPolicy проверяет название политики медленного клиента
+func ParseSlowConsumerPolicy(s string) (SlowConsumerPolicy, error) {
+	switch p := SlowConsumerPolicy(s); p {
+	case SlowConsumerDropNewest, SlowConsumerDropOldest, SlowConsumerCoalesce, SlowConsumerDisconnect:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown slow consumer policy %q", s)
+}
+
+// slowConsumerPolicyFromProto переводит политику из запроса; UNSPECIFIED — политика сервера
+func slowConsumerPolicyFromProto(p pb.SlowConsumerPolicy, def SlowConsumerPolicy) SlowConsumerPolicy {
+	switch p {
+	case pb.SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DROP_NEWEST:
+		return SlowConsumerDropNewest
+	case pb.SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DROP_OLDEST:
+		return SlowConsumerDropOldest
+	case pb.SlowConsumerPolicy_SLOW_CONSUMER_POLICY_COALESCE:
+		return SlowConsumerCoalesce
+	case pb.SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DISCONNECT:
+		return SlowConsumerDisconnect
+	}
+	return def
+}
+
+// subscriber — клиент DataExchange, его подписка и собственная очередь отправки.
+// Рассылка только ставит значения в очередь и никогда не ждет клиента.
+type subscriber struct {
+	notify   chan struct{}  // сигнал отправителю, что в очереди появились значения
+	identity *auth.Identity // клиент; nil — аутентификация выключена
+
+	mu         sync.Mutex
+	filters    []string // фильтры "device/parameter"; пусто — все значения
+	policy     SlowConsumerPolicy
+	limit      int
+	queue      []*pb.Value
+	dropped    uint64 // отброшено с момента последней отправки
+	overflowed bool   // очередь переполнена при политике disconnect
+	closed     bool
+
+	// Пока клиенту досылается снимок или повтор, живые значения копятся в backlog
+	replaying bool
+	backlog   []*pb.Value
+}
+
+func newSubscriber(identity *auth.Identity, policy SlowConsumerPolicy, limit int) *subscriber {
+	return &subscriber{
+		notify:   make(chan struct{}, 1),
+		identity: identity,
+		policy:   policy,
+		limit:    limit,
+	}
+}
+
+// allowed проверяет, можно ли клиенту получать значения ряда
+func (sub *subscriber) allowed(device, parameter string) bool {
+	return sub.identity.Can(auth.PermSubscribe, device, parameter)
+}
+
+// configure меняет подписку клиента; фильтры должны быть проверены validateFilters
+func (sub *subscriber) configure(filters []string, policy SlowConsumerPolicy, limit int) {
+	sub.mu.Lock()
+	sub.filters = filters
+	sub.policy = policy
+	sub.limit = limit
+	sub.mu.Unlock()
+}
+
+// enqueue ставит живое значение в очередь по политике клиента; false — значение отброшено
+func (sub *subscriber) enqueue(msg *pb.Value) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed || !matchFilters(sub.filters, msg.Device, msg.Parameter) || !sub.allowed(msg.Device, msg.Parameter) {
+		return true
+	}
+	if sub.replaying {
+		if len(sub.backlog) >= replayBacklogLimit {
+			sub.dropped++
+			return false
+		}
+		sub.backlog = append(sub.backlog, msg)
+		return true
+	}
+
+	if len(sub.queue) < sub.limit {
+		sub.queue = append(sub.queue, msg)
+		sub.signal()
+		return true
+	}
+
+	switch sub.policy {
+	case SlowConsumerDropOldest:
+		// Ответы на команды не выбрасываем: клиент не должен терять результат команды
+		evicted := false
+		for i, v := range sub.queue {
+			if v.Result == nil {
+				sub.queue = append(append(sub.queue[:i], sub.queue[i+1:]...), msg)
+				evicted = true
+				break
+			}
+		}
+		if !evicted {
+			sub.dropped++
+			return false
+		}
+	case SlowConsumerCoalesce:
+		// Ждущее значение ряда убираем, последнее ставим в конец, чтобы номера шли по порядку.
+		// Ряд без значения в очереди добавляется сверх лимита, так что очередь ограничена числом рядов.
+		replaced := false
+		for i, v := range sub.queue {
+			if v.Device == msg.Device && v.Parameter == msg.Parameter && v.Result == nil {
+				sub.queue = append(sub.queue[:i], sub.queue[i+1:]...)
+				replaced = true
+				break
+			}
+		}
+		sub.queue = append(sub.queue, msg)
+		if !replaced {
+			sub.signal()
+			return true
+		}
+	case SlowConsumerDisconnect:
+		sub.overflowed = true
+		sub.signal()
+		return false
+	default:
+		sub.dropped++
+		return false
+	}
+	sub.dropped++
+	sub.signal()
+	return false
+}
+
+// push ставит в очередь ответ на команду или значение снимка вне политики переполнения
+func (sub *subscriber) push(values ...*pb.Value) {
+	if len(values) == 0 {
+		return
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.queue = append(sub.queue, values...)
+	sub.signal()
+}
+
+// startReplay переводит клиента в режим досылки: живые значения копятся в backlog
+func (sub *subscriber) startReplay() {
+	sub.mu.Lock()
+	sub.replaying = true
+	sub.mu.Unlock()
+}
+
+// finishReplay ставит накопленное за время досылки в очередь и возвращает клиента к живому потоку
+func (sub *subscriber) finishReplay() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.queue = append(sub.queue, sub.backlog...)
+	sub.backlog = nil
+	sub.replaying = false
+	if len(sub.queue) > 0 {
+		sub.signal()
+	}
+}
+
+// take забирает очередь целиком; число отброшенных значений передается
+// клиенту в первом из них
+func (sub *subscriber) take() (values []*pb.Value, overflowed bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	values, sub.queue = sub.queue, nil
+	if sub.dropped > 0 && len(values) > 0 {
+		// Сообщение общее для всех клиентов — счетчик пишем в копию
+		first := proto.Clone(values[0]).(*pb.Value)
+		first.Dropped = sub.dropped
+		values[0] = first
+		sub.dropped = 0
+	}
+	return values, sub.overflowed
+}
+
+// close отключает клиента от рассылки
+func (sub *subscriber) close() {
+	sub.mu.Lock()
+	sub.closed = true
+	sub.queue = nil
+	sub.backlog = nil
+	sub.mu.Unlock()
+}
+
+// signal будит отправителя; вызывается под sub.mu
+func (sub *subscriber) signal() {
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+func validateFilters(filters []string) error {
+	for _, f := range filters {
+		if err := topic.ValidateFilter(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchFilters проверяет ряд по фильтрам "device/parameter"; пустой список подходит под все
+func matchFilters(filters []string, device, parameter string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	series := device + "/" + parameter
+	for _, f := range filters {
+		if topic.MatchFilter(f, series) {
+			return true
+		}
+	}
+	return false
+}
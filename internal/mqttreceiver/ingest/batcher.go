@@ -0,0 +1,206 @@
+// internal/mqttreceiver/ingest/batcher.go
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/metrics"
+	"brutus/internal/mqttreceiver/storage"
+)
+
+const (
+	// Задержка перед повтором пачки после ошибки записи; удваивается до batchMaxRetryBackoff
+	batchRetryBackoff    = time.Second
+	batchMaxRetryBackoff = 30 * time.Second
+	// Сколько раз пробовать запись с постоянной ошибкой (не связь с БД и не блокировка)
+	batchMaxAttempts = 3
+)
+
+// Message — сообщение в принимающем канале с брокера
+type Message struct {
+	Device    string
+	Parameter string
+	Value     string
+	Timestamp time.Time // время получения от брокера или публикации из свойства timestamp MQTT 5
+	DedupKey  string    // ключ идемпотентной записи в кластерном режиме
+
+	// Больше нуля — повторная доставка без времени публикации: строка пропускается,
+	// если такое же значение ряда записано не раньше чем за DedupWindow
+	DedupWindow time.Duration
+}
+
+// Deduplicate задает ключ, по которому реплики записывают одно сообщение один раз:
+// хеш ряда и значения. Строка истории уникальна по ключу и времени, поэтому с временем
+// публикации (свойство timestamp MQTT 5) повтор отсекается точно. Без него остается время
+// получения, и совпадающие значения не склеиваются; повторно доставленное брокером
+// сообщение (флаг DUP) сверяется с записанным за window до него.
+func (m *Message) Deduplicate(sourceTime time.Time, redelivered bool, window time.Duration) {
+	if !sourceTime.IsZero() {
+		m.Timestamp = sourceTime
+	} else if redelivered {
+		m.DedupWindow = window
+	}
+
+	h := sha256.New()
+	for _, part := range []string{m.Device, m.Parameter, m.Value} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	m.DedupKey = hex.EncodeToString(h.Sum(nil))
+}
+
+// Batcher собирает сообщения из очереди в пачки по размеру или по времени
+// и записывает каждую пачку одной транзакцией
+type Batcher struct {
+	db       storage.Store
+	queue    Queue
+	size     int
+	interval time.Duration
+	onSaved  func([]Message)
+
+	retryBackoff time.Duration
+}
+
+// NewBatcher создает писателя пачек; onSaved вызывается после успешной записи
+func NewBatcher(db storage.Store, queue Queue, size int, interval time.Duration, onSaved func([]Message)) *Batcher {
+	return &Batcher{
+		db:       db,
+		queue:    queue,
+		size:     size,
+		interval: interval,
+		onSaved:  onSaved,
+
+		retryBackoff: batchRetryBackoff,
+	}
+}
+
+// Run обрабатывает очередь, пока она не закрыта
+func (b *Batcher) Run() {
+	batch := make([]Entry, 0, b.size)
+	timer := time.NewTimer(b.interval)
+	timer.Stop()
+	entries := b.queue.Entries()
+
+	for {
+		select {
+		case msg, ok := <-entries:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(b.interval)
+			}
+			batch = append(batch, msg)
+			if len(batch) >= b.size {
+				timer.Stop()
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		case <-timer.C:
+			b.flush(batch)
+			batch = batch[:0]
+		}
+		metrics.IngestQueueLength.Set(float64(b.queue.Len()))
+	}
+}
+
+// save записывает пачку. Из журнала на диске пачка не удаляется, пока не будет записана:
+// временные ошибки (нет связи с БД, БД занята) повторяются без ограничения, постоянные —
+// до attempts попыток. Очередь в памяти не повторяет запись.
+func (b *Batcher) save(samples []storage.Sample, attempts int) error {
+	backoff := b.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := b.db.SaveBatch(samples)
+		if err == nil || !b.queue.Durable() {
+			return err
+		}
+		transient := storage.IsTransient(err)
+		if !transient && attempt >= attempts {
+			return err
+		}
+		logger.Log.Error().
+			Str("component", "ingestWorker").
+			Int("batch_size", len(samples)).
+			Bool("transient", transient).
+			Dur("retry_in", backoff).
+			Err(err).
+			Msg("Failed to save batch, will retry")
+		time.Sleep(backoff)
+		backoff = min(backoff*2, batchMaxRetryBackoff)
+	}
+}
+
+// drop учитывает незаписанные сообщения; подтверждает их вызывающий, чтобы они не держали очередь
+func (b *Batcher) drop(batch []Entry, err error) {
+	metrics.MsgErrors.Add(float64(len(batch)))
+	event := logger.Log.Error().
+		Str("component", "ingestWorker").
+		Int("batch_size", len(batch))
+	if len(batch) == 1 {
+		event = event.
+			Str("device", batch[0].Message.Device).
+			Str("parameter", batch[0].Message.Parameter)
+	}
+	event.Err(err).Msg("Failed to save batch, dropped")
+}
+
+func (b *Batcher) flush(batch []Entry) {
+	if len(batch) == 0 {
+		return
+	}
+	start := time.Now()
+
+	messages := make([]Message, len(batch))
+	samples := make([]storage.Sample, len(batch))
+	for i, e := range batch {
+		msg := e.Message
+		messages[i] = msg
+		samples[i] = storage.Sample{
+			Device:    msg.Device,
+			Parameter: msg.Parameter,
+			Value:     msg.Value,
+			Timestamp: msg.Timestamp,
+			DedupKey:  msg.DedupKey,
+
+			DedupWindow: msg.DedupWindow,
+		}
+	}
+
+	// Очередь в памяти, как и раньше, теряет пачку при ошибке записи, чтобы не блокироваться
+	if err := b.save(samples, batchMaxAttempts); err != nil {
+		if !b.queue.Durable() || len(samples) == 1 {
+			b.drop(batch, err)
+			b.queue.Ack(batch)
+			return
+		}
+		// Постоянная ошибка из-за одного значения не должна терять всю пачку:
+		// пишем по одному и отбрасываем только то, что записать нельзя. Пачка уже исчерпала
+		// попытки, поэтому постоянная ошибка значения не повторяется
+		saved := messages[:0]
+		for i := range samples {
+			if err := b.save(samples[i:i+1], 1); err != nil {
+				b.drop(batch[i:i+1], err)
+				continue
+			}
+			saved = append(saved, batch[i].Message)
+		}
+		messages = saved
+	}
+	b.queue.Ack(batch)
+
+	metrics.BatchSize.Observe(float64(len(batch)))
+	metrics.BatchFlushTime.Observe(time.Since(start).Seconds())
+
+	if b.onSaved != nil {
+		b.onSaved(messages)
+	}
+	// Время обработки считаем от получения сообщения до записи его пачки
+	now := time.Now()
+	for _, msg := range messages {
+		metrics.ProcessingTime.Observe(now.Sub(msg.Timestamp).Seconds())
+	}
+}
@@ -0,0 +1,115 @@
+// internal/mqttreceiver/ingest/batcher_test.go
+package ingest
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"brutus/internal/mqttreceiver/storage"
+)
+
+// fakeStore отклоняет значение "bad" постоянной ошибкой, а первые transient вызовов —
+// временной; остальные методы хранилища тесту не нужны
+type fakeStore struct {
+	storage.Store
+
+	mu        sync.Mutex
+	transient int
+	calls     int
+	saved     []string
+}
+
+func (s *fakeStore) SaveBatch(samples []storage.Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.transient > 0 {
+		s.transient--
+		return driver.ErrBadConn
+	}
+	for _, smp := range samples {
+		if smp.Value == "bad" {
+			return errors.New("invalid byte sequence for encoding")
+		}
+	}
+	for _, smp := range samples {
+		s.saved = append(s.saved, smp.Value)
+	}
+	return nil
+}
+
+// takeEntries забирает n записей из очереди
+func takeEntries(t *testing.T, q Queue, n int) []Entry {
+	t.Helper()
+	entries := make([]Entry, 0, n)
+	for len(entries) < n {
+		select {
+		case e := <-q.Entries():
+			entries = append(entries, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of %d entries", len(entries), n)
+		}
+	}
+	return entries
+}
+
+func TestBatcherFlush(t *testing.T) {
+	tests := []struct {
+		name      string
+		durable   bool
+		values    []string
+		transient int
+		saved     []string
+		calls     int
+	}{
+		{"saved at once", true, []string{"1", "2"}, 0, []string{"1", "2"}, 1},
+		{"transient errors retried", true, []string{"1", "2"}, 4, []string{"1", "2"}, 5},
+		{"bad value dropped, rest saved", true, []string{"1", "bad", "3"}, 0, []string{"1", "3"}, batchMaxAttempts + 3},
+		{"single bad value dropped", true, []string{"bad"}, 0, nil, batchMaxAttempts},
+		{"memory queue drops batch", false, []string{"1", "bad"}, 0, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q Queue = NewMemoryQueue(10, OverflowBlock)
+			if tt.durable {
+				dq, err := OpenDiskQueue(t.TempDir(), 1<<20, OverflowBlock)
+				if err != nil {
+					t.Fatal(err)
+				}
+				q = dq
+			}
+			defer q.Close()
+
+			db := &fakeStore{transient: tt.transient}
+			var onSaved []string
+			b := NewBatcher(db, q, 10, time.Second, func(messages []Message) {
+				for _, m := range messages {
+					onSaved = append(onSaved, m.Value)
+				}
+			})
+			b.retryBackoff = time.Millisecond
+
+			for _, v := range tt.values {
+				q.Push(Message{Device: "wb-adc", Parameter: "A1", Value: v, Timestamp: time.Now()})
+			}
+			b.flush(takeEntries(t, q, len(tt.values)))
+
+			if !slices.Equal(db.saved, tt.saved) {
+				t.Errorf("saved %v, want %v", db.saved, tt.saved)
+			}
+			if len(tt.saved) > 0 && !slices.Equal(onSaved, tt.saved) {
+				t.Errorf("onSaved got %v, want %v", onSaved, tt.saved)
+			}
+			if db.calls != tt.calls {
+				t.Errorf("SaveBatch called %d times, want %d", db.calls, tt.calls)
+			}
+			if n := q.Len(); n != 0 {
+				t.Errorf("queue length after flush = %d, want 0", n)
+			}
+		})
+	}
+}
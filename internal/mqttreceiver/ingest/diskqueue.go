@@ -0,0 +1,583 @@
+// internal/mqttreceiver/ingest/diskqueue.go
+package ingest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/metrics"
+)
+
+const (
+	segmentExt     = ".seg"
+	checkpointFile = "checkpoint"
+	recordHeader   = 8 // длина (uint32) + CRC32 (uint32)
+	syncInterval   = time.Second
+	// Сколько Close ждет подтверждения записей, уже переданных воркерам
+	closeTimeout = 10 * time.Second
+)
+
+// segment — файл журнала с записями, номера которых начинаются с firstSeq
+type segment struct {
+	firstSeq uint64
+	count    uint64
+	size     int64
+	path     string
+}
+
+// DiskQueue — очередь с журналом на диске (write-ahead): сообщения пишутся в сегменты
+// до передачи воркерам и удаляются после подтверждения записи в БД.
+// После перезапуска неподтвержденные сообщения отдаются повторно.
+// Доставка «хотя бы один раз»: сообщения, подтвержденные после последнего
+// сохранения контрольной точки, при аварийном перезапуске будут записаны повторно.
+type DiskQueue struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	policy       OverflowPolicy
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	segments  []*segment
+	writer    *os.File
+	bytes     int64
+	nextSeq   uint64 // номер следующей записи
+	readSeq   uint64 // номер следующей записи для воркеров
+	committed uint64 // все записи с номером меньше подтверждены
+	acked     map[uint64]struct{}
+	saved     uint64 // committed на момент последней контрольной точки
+	sent      uint64 // все записи с номером меньше переданы воркерам
+	closed    bool
+
+	out  chan Entry
+	done chan struct{}
+}
+
+// OpenDiskQueue открывает журнал в каталоге dir и готовит неподтвержденные записи к повтору
+func OpenDiskQueue(dir string, maxBytes int64, policy OverflowPolicy) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	q := &DiskQueue{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: min(maxBytes/4, 8<<20),
+		policy:       policy,
+		acked:        make(map[uint64]struct{}),
+		out:          make(chan Entry, 1024),
+		done:         make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	replayed := q.nextSeq - q.readSeq
+	metrics.DiskQueueReplayed.Add(float64(replayed))
+	q.updateMetrics()
+	logger.Log.Info().
+		Str("component", "ingestQueue").
+		Str("dir", dir).
+		Uint64("replay", replayed).
+		Int64("bytes", q.bytes).
+		Msg("Disk queue opened")
+
+	go q.readLoop()
+	go q.syncLoop()
+	return q, nil
+}
+
+// load читает контрольную точку и сегменты, обрезая недописанный хвост
+func (q *DiskQueue) load() error {
+	if data, err := os.ReadFile(filepath.Join(q.dir, checkpointFile)); err == nil {
+		q.committed, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid disk queue checkpoint: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	q.saved = q.committed
+
+	names, err := filepath.Glob(filepath.Join(q.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &segment{firstSeq: first, path: name})
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].firstSeq < q.segments[j].firstSeq })
+
+	// Сегменты удаляются сразу, а контрольная точка сохраняется раз в syncInterval:
+	// после аварии она может отставать от первого оставшегося сегмента
+	if len(q.segments) > 0 {
+		q.committed = max(q.committed, q.segments[0].firstSeq)
+		q.saved = q.committed
+	}
+
+	q.nextSeq = q.committed
+	for _, seg := range q.segments {
+		if err := scanSegment(seg); err != nil {
+			return err
+		}
+		q.nextSeq = max(q.nextSeq, seg.firstSeq+seg.count)
+	}
+
+	// Полностью подтвержденные сегменты больше не нужны
+	q.removeCommitted()
+
+	if len(q.segments) == 0 {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	} else {
+		last := q.segments[len(q.segments)-1]
+		if q.writer, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return err
+		}
+	}
+	for _, seg := range q.segments {
+		q.bytes += seg.size
+	}
+
+	q.readSeq = max(q.committed, q.segments[0].firstSeq)
+	return nil
+}
+
+// scanSegment считает целые записи сегмента и обрезает поврежденный хвост
+func scanSegment(seg *segment) error {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		n, err := readRecord(r, nil)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Log.Warn().
+					Str("component", "ingestQueue").
+					Str("segment", seg.path).
+					Int64("offset", seg.size).
+					Err(err).
+					Msg("Truncating damaged disk queue segment")
+			}
+			return f.Truncate(seg.size)
+		}
+		seg.size += n
+		seg.count++
+	}
+}
+
+// readRecord читает одну запись; payload может быть nil, если нужна только длина
+func readRecord(r io.Reader, payload *[]byte) (int64, error) {
+	var header [recordHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("partial record header")
+		}
+		return 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	sum := binary.LittleEndian.Uint32(header[4:8])
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, fmt.Errorf("partial record: %w", err)
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return 0, fmt.Errorf("record checksum mismatch")
+	}
+	if payload != nil {
+		*payload = data
+	}
+	return int64(recordHeader + size), nil
+}
+
+// rotate начинает новый сегмент с номера nextSeq
+func (q *DiskQueue) rotate() error {
+	if q.writer != nil {
+		if err := q.writer.Close(); err != nil {
+			return err
+		}
+	}
+	seg := &segment{
+		firstSeq: q.nextSeq,
+		path:     filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextSeq, segmentExt)),
+	}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	q.writer = f
+	q.segments = append(q.segments, seg)
+	return nil
+}
+
+// Push записывает сообщение в журнал по политике переполнения
+func (q *DiskQueue) Push(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		dropped(msg, "failed to encode: "+err.Error())
+		return
+	}
+	record := make([]byte, recordHeader+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeader:], data)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && q.bytes+int64(len(record)) > q.maxBytes {
+		switch q.policy {
+		case OverflowBlock:
+			q.cond.Wait()
+			continue
+		case OverflowDropOldest:
+			// Выбрасываем самый старый сегмент целиком; текущий сегмент записи не трогаем
+			if len(q.segments) > 1 {
+				q.dropOldestSegment()
+				continue
+			}
+		}
+		dropped(msg, "disk queue full")
+		return
+	}
+	if q.closed {
+		dropped(msg, "disk queue closed")
+		return
+	}
+
+	last := q.segments[len(q.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > q.segmentBytes {
+		if err := q.rotate(); err != nil {
+			q.writeFailed(msg, err)
+			return
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	if _, err := q.writer.Write(record); err != nil {
+		q.writeFailed(msg, err)
+		return
+	}
+
+	last.size += int64(len(record))
+	last.count++
+	q.bytes += int64(len(record))
+	q.nextSeq++
+	q.updateMetrics()
+	q.cond.Broadcast()
+}
+
+func (q *DiskQueue) writeFailed(msg Message, err error) {
+	logger.Log.Error().
+		Str("component", "ingestQueue").
+		Err(err).
+		Msg("Failed to write to disk queue")
+	dropped(msg, "disk queue write failed")
+}
+
+// dropOldestSegment удаляет самый старый сегмент вместе с неподтвержденными записями
+func (q *DiskQueue) dropOldestSegment() {
+	seg := q.segments[0]
+	q.segments = q.segments[1:]
+	q.bytes -= seg.size
+	_ = os.Remove(seg.path)
+
+	next := q.segments[0].firstSeq
+	lost := next - max(q.committed, seg.firstSeq)
+	for seq := range q.acked {
+		if seq < next {
+			delete(q.acked, seq)
+		}
+	}
+	q.committed = max(q.committed, next)
+	q.readSeq = max(q.readSeq, next)
+
+	metrics.DroppedMessages.Add(float64(lost))
+	logger.Log.Warn().
+		Str("component", "ingestQueue").
+		Uint64("dropped", lost).
+		Msg("Disk queue full, dropped oldest segment")
+}
+
+// Entries отдает сообщения воркерам по порядку номеров
+func (q *DiskQueue) Entries() <-chan Entry { return q.out }
+
+func (q *DiskQueue) readLoop() {
+	defer close(q.out)
+
+	var (
+		file    *os.File
+		reader  *bufio.Reader
+		fileSeg *segment
+		fileSeq uint64 // номер записи, на которой стоит reader
+	)
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		for !q.closed && q.readSeq >= q.nextSeq {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		seq := q.readSeq
+		var (
+			seg    *segment
+			segEnd = q.nextSeq
+		)
+		for _, s := range q.segments {
+			if seq < s.firstSeq {
+				segEnd = s.firstSeq
+				break
+			}
+			if seq < s.firstSeq+s.count {
+				seg, segEnd = s, s.firstSeq+s.count
+				break
+			}
+		}
+		q.mu.Unlock()
+
+		if seg == nil {
+			// Номера между сегментами: хвост предыдущего сегмента обрезан при загрузке
+			q.skip(segEnd)
+			continue
+		}
+
+		if seg != fileSeg || fileSeq > seq {
+			if file != nil {
+				file.Close()
+			}
+			f, err := os.Open(seg.path)
+			if err != nil {
+				logger.Log.Error().Str("component", "ingestQueue").Err(err).Msg("Failed to open disk queue segment")
+				q.skip(segEnd)
+				file, fileSeg = nil, nil
+				continue
+			}
+			file, reader, fileSeg, fileSeq = f, bufio.NewReader(f), seg, seg.firstSeq
+		}
+
+		// Пропускаем уже подтвержденные записи в начале сегмента
+		var payload []byte
+		for fileSeq <= seq {
+			if _, err := readRecord(reader, &payload); err != nil {
+				logger.Log.Error().Str("component", "ingestQueue").Err(err).Msg("Failed to read disk queue record")
+				q.skip(segEnd)
+				fileSeg = nil
+				break
+			}
+			fileSeq++
+		}
+		if fileSeg == nil {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			logger.Log.Error().Str("component", "ingestQueue").Err(err).Msg("Failed to decode disk queue record")
+			q.skip(seq + 1)
+			continue
+		}
+
+		q.mu.Lock()
+		if q.readSeq == seq {
+			q.readSeq++
+		}
+		q.sent = seq + 1
+		q.mu.Unlock()
+
+		select {
+		case q.out <- Entry{Message: msg, seq: seq}:
+		case <-q.done:
+			// Запись воркерам не досталась — Close не ждет ее подтверждения
+			q.mu.Lock()
+			q.sent = seq
+			q.cond.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+	}
+}
+
+// skip пропускает нечитаемые записи до номера to и считает их подтвержденными,
+// иначе контрольная точка остановилась бы на них навсегда
+func (q *DiskQueue) skip(to uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for seq := max(q.readSeq, q.committed); seq < to; seq++ {
+		q.acked[seq] = struct{}{}
+		metrics.MsgErrors.Inc()
+	}
+	q.readSeq = max(q.readSeq, to)
+	q.commit()
+}
+
+// Ack подтверждает записи и удаляет сегменты, которые подтверждены полностью
+func (q *DiskQueue) Ack(entries []Entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, e := range entries {
+		if e.seq >= q.committed {
+			q.acked[e.seq] = struct{}{}
+		}
+	}
+	q.commit()
+}
+
+// commit сдвигает границу подтверждения по непрерывной последовательности номеров
+func (q *DiskQueue) commit() {
+	for {
+		if _, ok := q.acked[q.committed]; !ok {
+			break
+		}
+		delete(q.acked, q.committed)
+		q.committed++
+	}
+
+	// Close ждет подтверждений, Push в режиме block — освобождения места
+	if q.removeCommitted() || q.closed {
+		q.cond.Broadcast()
+	}
+	q.updateMetrics()
+}
+
+// removeCommitted удаляет подтвержденные сегменты, кроме текущего сегмента записи
+func (q *DiskQueue) removeCommitted() bool {
+	removed := false
+	for len(q.segments) > 1 {
+		seg := q.segments[0]
+		if seg.firstSeq+seg.count > q.committed {
+			break
+		}
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Log.Error().Str("component", "ingestQueue").Err(err).Msg("Failed to remove disk queue segment")
+			break
+		}
+		q.segments = q.segments[1:]
+		q.bytes -= seg.size
+		removed = true
+	}
+	return removed
+}
+
+// syncLoop периодически сбрасывает журнал на диск и сохраняет контрольную точку
+func (q *DiskQueue) syncLoop() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.mu.Lock()
+			if err := q.sync(); err != nil {
+				logger.Log.Error().Str("component", "ingestQueue").Err(err).Msg("Failed to sync disk queue")
+			}
+			q.mu.Unlock()
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *DiskQueue) sync() error {
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	if q.committed == q.saved {
+		return nil
+	}
+
+	tmp := filepath.Join(q.dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(q.committed, 10)), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, checkpointFile)); err != nil {
+		return err
+	}
+	q.saved = q.committed
+	return nil
+}
+
+// Len возвращает число неподтвержденных сообщений
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int(q.nextSeq - q.committed)
+}
+
+// Durable: неподтвержденные записи остаются в журнале и после перезапуска
+func (q *DiskQueue) Durable() bool { return true }
+
+func (q *DiskQueue) updateMetrics() {
+	metrics.DiskQueueDepth.Set(float64(q.nextSeq - q.committed))
+	metrics.DiskQueueBytes.Set(float64(q.bytes))
+}
+
+// Close останавливает прием и выдачу сообщений, ждет подтверждения уже выданных
+// воркерам записей (не дольше closeTimeout) и сохраняет контрольную точку
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.done)
+	q.cond.Broadcast()
+
+	expired := false
+	timer := time.AfterFunc(closeTimeout, func() {
+		q.mu.Lock()
+		expired = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	for q.committed < q.sent && !expired {
+		q.cond.Wait()
+	}
+	timer.Stop()
+	if q.committed < q.sent {
+		logger.Log.Warn().
+			Str("component", "ingestQueue").
+			Uint64("unacked", q.sent-q.committed).
+			Msg("Disk queue closed with unacknowledged records, they will be replayed")
+	}
+
+	err := q.sync()
+	if cerr := q.writer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+// internal/mqttreceiver/ingest/queue.go
+package ingest
+
+import (
+	"fmt"
+	"sync"
+
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/metrics"
+)
+
+// OverflowPolicy — что делать с сообщением, когда очередь заполнена
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"       // ждать освобождения места
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // выбросить самые старые сообщения
+	OverflowDropNewest OverflowPolicy = "drop_newest" // выбросить входящее сообщение
+)
+
+// ParseOverflowPolicy проверяет название политики переполнения
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// Entry — сообщение из очереди вместе с его порядковым номером для подтверждения
+type Entry struct {
+	Message
+	seq uint64
+}
+
+// Queue — очередь между обработчиком MQTT и воркерами записи
+type Queue interface {
+	// Push ставит сообщение в очередь по политике переполнения
+	Push(msg Message)
+	// Entries отдает сообщения воркерам; канал закрывается после Close
+	Entries() <-chan Entry
+	// Ack подтверждает, что сообщения записаны и их можно забыть
+	Ack(entries []Entry)
+	// Len возвращает число неподтвержденных сообщений
+	Len() int
+	// Durable сообщает, хранит ли очередь сообщения до подтверждения:
+	// такие пачки при ошибке записи повторяются, а не выбрасываются
+	Durable() bool
+	Close() error
+}
+
+// MemoryQueue — очередь в памяти: буферизованный канал, теряется при перезапуске
+type MemoryQueue struct {
+	ch     chan Entry
+	policy OverflowPolicy
+
+	// Обработчик MQTT может успеть вызвать Push после Close
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewMemoryQueue создает очередь в памяти заданного размера
+func NewMemoryQueue(size int, policy OverflowPolicy) *MemoryQueue {
+	return &MemoryQueue{ch: make(chan Entry, size), policy: policy}
+}
+
+func (q *MemoryQueue) Push(msg Message) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		dropped(msg, "ingestQueue closed")
+		return
+	}
+	entry := Entry{Message: msg}
+
+	switch q.policy {
+	case OverflowBlock:
+		q.ch <- entry
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.ch <- entry:
+				metrics.IngestQueueLength.Set(float64(len(q.ch)))
+				return
+			default:
+			}
+			// Освобождаем место, выбрасывая самое старое сообщение
+			select {
+			case old := <-q.ch:
+				dropped(old.Message, "ingestQueue full, dropped oldest")
+			default:
+			}
+		}
+	default:
+		select {
+		case q.ch <- entry:
+		default:
+			dropped(msg, "ingestQueue full")
+			return
+		}
+	}
+	metrics.IngestQueueLength.Set(float64(len(q.ch)))
+}
+
+func (q *MemoryQueue) Entries() <-chan Entry { return q.ch }
+
+func (q *MemoryQueue) Ack([]Entry) {}
+
+func (q *MemoryQueue) Len() int { return len(q.ch) }
+
+func (q *MemoryQueue) Durable() bool { return false }
+
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.ch)
+	return nil
+}
+
+func dropped(msg Message, reason string) {
+	metrics.DroppedMessages.Inc()
+	logger.Log.Warn().
+		Str("component", "ingestQueue").
+		Str("device", msg.Device).
+		Str("parameter", msg.Parameter).
+		Msg("Dropped incoming message — " + reason)
+}
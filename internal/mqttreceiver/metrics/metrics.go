@@ -34,6 +34,56 @@ var (
 		Name: "mqttreceiver_broadcast_dropped_total",
 		Help: "Total number of messages dropped during gRPC broadcast due to slow clients.",
 	})
+	BatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mqttreceiver_ingest_batch_size",
+		Help:    "Number of messages written to storage per batch.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+	BatchFlushTime = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mqttreceiver_ingest_batch_flush_seconds",
+		Help:    "Time taken to write one ingest batch to storage.",
+		Buckets: prometheus.DefBuckets,
+	})
+	DiskQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mqttreceiver_disk_queue_depth",
+		Help: "Number of unacknowledged messages in the on-disk ingest queue.",
+	})
+	DiskQueueBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mqttreceiver_disk_queue_bytes",
+		Help: "Size of the on-disk ingest queue segments in bytes.",
+	})
+	DiskQueueReplayed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_disk_queue_replayed_total",
+		Help: "Total number of messages replayed from the on-disk ingest queue on startup.",
+	})
+	SlowConsumerDisconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_grpc_slow_consumer_disconnects_total",
+		Help: "Total number of gRPC clients disconnected because their queue overflowed.",
+	})
+	ReplayedValues = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_grpc_replayed_values_total",
+		Help: "Total number of snapshot and resumed values sent to gRPC clients before live updates.",
+	})
+	SinkPointsSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_sink_points_sent_total",
+		Help: "Total number of points forwarded to the InfluxDB sink.",
+	})
+	SinkErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_sink_errors_total",
+		Help: "Total number of failed write requests to the InfluxDB sink.",
+	})
+	SinkSpooled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_sink_spooled_points_total",
+		Help: "Total number of points written to the sink spool file while the endpoint was unavailable.",
+	})
+	SinkDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_sink_dropped_points_total",
+		Help: "Total number of points dropped because the sink spool file was full or unwritable.",
+	})
+	DuplicateMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mqttreceiver_duplicate_messages_total",
+		Help: "Total number of history rows skipped because another replica already wrote them.",
+	})
 )
 
 func init() {
@@ -42,5 +92,10 @@ func init() {
 		ProcessingTime, BrokerConnected,
 		DroppedMessages, IngestQueueLength,
 		BroadcastDropped,
+		BatchSize, BatchFlushTime,
+		DiskQueueDepth, DiskQueueBytes, DiskQueueReplayed,
+		SinkPointsSent, SinkErrors, SinkSpooled, SinkDropped,
+		ReplayedValues, SlowConsumerDisconnects,
+		DuplicateMessages,
 	)
 }
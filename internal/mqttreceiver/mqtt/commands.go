@@ -0,0 +1,190 @@
+// internal/mqttreceiver/mqtt/commands.go
+package mqtt
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandStatus — итог выполнения команды
+type CommandStatus int
+
+const (
+	CommandApplied CommandStatus = iota
+	CommandTimedOut
+	CommandRejected
+)
+
+func (s CommandStatus) String() string {
+	switch s {
+	case CommandApplied:
+		return "applied"
+	case CommandTimedOut:
+		return "timed_out"
+	case CommandRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// CommandResult — результат команды, отправленной в топик /on
+type CommandResult struct {
+	Status CommandStatus
+	Reason string
+}
+
+type pendingCommand struct {
+	device      string
+	parameter   string
+	value       string
+	correlation string // correlation data запроса MQTT 5 в hex; пусто — без ответа
+	done        chan CommandResult
+}
+
+// commandTracker ждет, пока устройство подтвердит команду новым значением в топике состояния
+// или ответом MQTT 5 с тем же correlation data
+type commandTracker struct {
+	mu         sync.Mutex
+	pending    map[string][]*pendingCommand
+	correlated map[string]*pendingCommand
+}
+
+func newCommandTracker() *commandTracker {
+	return &commandTracker{
+		pending:    make(map[string][]*pendingCommand),
+		correlated: make(map[string]*pendingCommand),
+	}
+}
+
+func commandKey(device, parameter string) string {
+	return device + "/" + parameter
+}
+
+func (t *commandTracker) add(device, parameter, value, correlation string) *pendingCommand {
+	pc := &pendingCommand{
+		device:      device,
+		parameter:   parameter,
+		value:       value,
+		correlation: correlation,
+		done:        make(chan CommandResult, 1),
+	}
+	key := commandKey(device, parameter)
+
+	t.mu.Lock()
+	t.pending[key] = append(t.pending[key], pc)
+	if correlation != "" {
+		t.correlated[correlation] = pc
+	}
+	t.mu.Unlock()
+	return pc
+}
+
+func (t *commandTracker) remove(device, parameter string, pc *pendingCommand) {
+	key := commandKey(device, parameter)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.correlated, pc.correlation)
+	list := t.pending[key]
+	for i, p := range list {
+		if p == pc {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(t.pending, key)
+	} else {
+		t.pending[key] = list
+	}
+}
+
+// confirm завершает ожидающие команды, значение которых совпало с пришедшим состоянием
+func (t *commandTracker) confirm(device, parameter, value string) {
+	t.finish(device, parameter, func(pc *pendingCommand) (CommandResult, bool) {
+		if !sameValue(pc.value, value) {
+			return CommandResult{}, false
+		}
+		return CommandResult{Status: CommandApplied}, true
+	})
+}
+
+// respond завершает команду по ответу MQTT 5; false — команда неизвестна или уже завершена
+func (t *commandTracker) respond(correlation string, res CommandResult) bool {
+	t.mu.Lock()
+	target := t.correlated[correlation]
+	t.mu.Unlock()
+	if target == nil {
+		return false
+	}
+
+	t.finish(target.device, target.parameter, func(pc *pendingCommand) (CommandResult, bool) {
+		return res, pc == target
+	})
+	return true
+}
+
+// reject завершает все ожидающие команды контрола с ошибкой
+func (t *commandTracker) reject(device, parameter, reason string) {
+	t.finish(device, parameter, func(*pendingCommand) (CommandResult, bool) {
+		return CommandResult{Status: CommandRejected, Reason: reason}, true
+	})
+}
+
+func (t *commandTracker) finish(device, parameter string, decide func(*pendingCommand) (CommandResult, bool)) {
+	key := commandKey(device, parameter)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.pending[key]
+	if len(list) == 0 {
+		return
+	}
+	rest := list[:0]
+	for _, pc := range list {
+		if res, ok := decide(pc); ok {
+			delete(t.correlated, pc.correlation)
+			pc.done <- res
+		} else {
+			rest = append(rest, pc)
+		}
+	}
+	if len(rest) == 0 {
+		delete(t.pending, key)
+	} else {
+		t.pending[key] = rest
+	}
+}
+
+// wait блокируется до подтверждения или таймаута
+func (t *commandTracker) wait(device, parameter string, pc *pendingCommand, timeout time.Duration) CommandResult {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-pc.done:
+		return res
+	case <-timer.C:
+		t.remove(device, parameter, pc)
+		// Подтверждение могло прийти между таймером и удалением
+		select {
+		case res := <-pc.done:
+			return res
+		default:
+		}
+		return CommandResult{Status: CommandTimedOut, Reason: "no state update within " + timeout.String()}
+	}
+}
+
+// sameValue сравнивает значения как числа, если оба числовые ("25" и "25.0")
+func sameValue(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
@@ -0,0 +1,81 @@
+// internal/mqttreceiver/mqtt/conn.go
+package mqtt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserProperty — пользовательское свойство сообщения MQTT 5
+type UserProperty struct {
+	Key   string
+	Value string
+}
+
+// ParseUserProperties разбирает список вида "key=value,key2=value2"
+func ParseUserProperties(s string) ([]UserProperty, error) {
+	var props []UserProperty
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("user property %q must be key=value", item)
+		}
+		props = append(props, UserProperty{Key: key, Value: strings.TrimSpace(value)})
+	}
+	return props, nil
+}
+
+// message — входящее сообщение независимо от версии протокола.
+// Свойства заполняются только в MQTT 5.
+type message struct {
+	topic           string
+	payload         []byte
+	contentType     string
+	userProperties  []UserProperty
+	correlationData []byte
+	duplicate       bool // флаг DUP: брокер доставляет сообщение повторно
+}
+
+// sourceTime возвращает время публикации из свойства timestamp MQTT 5:
+// миллисекунды Unix или RFC 3339; нулевое время — свойства нет или оно не разобрано
+func (msg message) sourceTime() time.Time {
+	for _, u := range msg.userProperties {
+		if u.Key != "timestamp" {
+			continue
+		}
+		if ms, err := strconv.ParseInt(u.Value, 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, u.Value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// publication — исходящее сообщение; в MQTT 3.1.1 свойства не передаются
+type publication struct {
+	topic           string
+	qos             byte
+	payload         []byte
+	contentType     string
+	userProperties  []UserProperty
+	expiry          time.Duration // 0 — без ограничения срока
+	responseTopic   string
+	correlationData []byte
+}
+
+// conn — подключение к брокеру по MQTT 3.1.1 или MQTT 5
+type conn interface {
+	subscribe(filter string, qos byte) error
+	publish(p publication) error
+	// close отключается от брокера, дождавшись отправки исходящих сообщений
+	close()
+}
@@ -0,0 +1,77 @@
+// internal/mqttreceiver/mqtt/meta.go
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"brutus/internal/mqttreceiver/topic"
+)
+
+// MetaUpdate — одно значение из meta-топиков Wiren Board.
+// Для meta самого устройства Control пустой.
+type MetaUpdate struct {
+	Device  string
+	Control string
+	Key     string
+	Value   string
+}
+
+// parseMetaTopic разбирает топики вида
+// /devices/<d>/meta[/<key>] и /devices/<d>/controls/<c>/meta[/<key>].
+// Пустой Key означает JSON-объект со всеми полями сразу (новый формат WB).
+func parseMetaTopic(t string) (MetaUpdate, bool) {
+	parts := topic.Split(t)
+	if len(parts) < 3 || parts[0] != "devices" {
+		return MetaUpdate{}, false
+	}
+
+	switch {
+	case parts[2] == "meta" && len(parts) <= 4:
+		u := MetaUpdate{Device: parts[1]}
+		if len(parts) == 4 {
+			u.Key = parts[3]
+		}
+		return u, true
+	case len(parts) >= 5 && len(parts) <= 6 && parts[2] == "controls" && parts[4] == "meta":
+		u := MetaUpdate{Device: parts[1], Control: parts[3]}
+		if len(parts) == 6 {
+			u.Key = parts[5]
+		}
+		return u, true
+	}
+	return MetaUpdate{}, false
+}
+
+// expandMeta превращает JSON-meta в набор отдельных обновлений по ключам
+func expandMeta(u MetaUpdate, payload []byte) ([]MetaUpdate, error) {
+	if u.Key != "" {
+		u.Value = string(payload)
+		return []MetaUpdate{u}, nil
+	}
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("invalid meta JSON: %w", err)
+	}
+
+	updates := make([]MetaUpdate, 0, len(fields))
+	for key, raw := range fields {
+		value := string(raw)
+		// Строки передаем без кавычек, объекты (title) и числа — как есть
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+		updates = append(updates, MetaUpdate{
+			Device:  u.Device,
+			Control: u.Control,
+			Key:     key,
+			Value:   value,
+		})
+	}
+	return updates, nil
+}
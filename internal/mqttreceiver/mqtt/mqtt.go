@@ -2,92 +2,165 @@
 package mqtt
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"brutus/internal/mqttreceiver/logger"
 	"brutus/internal/mqttreceiver/metrics"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"brutus/internal/mqttreceiver/topic"
 )
 
+// Options — параметры подключения к брокеру
+type Options struct {
+	BrokerURL       string
+	ClientID        string
+	ProtocolVersion int // 3 — MQTT 3.1.1, 5 — MQTT 5
+	Username        string
+	Password        string
+	TLS             *tls.Config // nil — ssl:// и wss:// проверяются по системным CA
+	Site            string      // префикс "<site>:" к ID устройств брокера; пусто — без префикса
+
+	Topics       []string
+	Patterns     []*topic.Pattern
+	MetaTopics   []string
+	SharedGroup  string // топики значений подписываются как $share/<group>/...; пусто — обычная подписка
+	SubscribeQoS byte
+	PublishQoS   byte
+
+	CommandSuffix  string
+	CommandTimeout time.Duration
+
+	// Свойства команд MQTT 5: срок жизни команды равен CommandTimeout,
+	// ответ устройства в ResponseTopic сопоставляется по correlation data
+	ContentType    string
+	UserProperties []UserProperty
+	ResponseTopic  string // пусто — команды подтверждаются только новым состоянием
+}
+
 type Client struct {
-	mqtt.Client
+	conn         conn
+	site         string
 	topics       []string
+	patterns     []*topic.Pattern
+	publishTo    *topic.Pattern
 	subscribeQoS byte
 	publishQoS   byte
-	onMessage    func(device, parameter, value string)
+	onMessage    func(device, parameter, value string, sourceTime time.Time, redelivered bool)
+	onMeta       func(MetaUpdate)
+
+	commandSuffix  string
+	commandTimeout time.Duration
+	commands       *commandTracker
+
+	contentType    string
+	userProperties []UserProperty
+	responseTopic  string
 }
 
-func NewClient(
-	brokerURL string,
-	clientID string,
-	topics []string,
-	subscribeQoS byte,
-	publishQoS byte,
-	username string,
-	password string,
-	onMessage func(string, string, string),
-) (*Client, error) {
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(brokerURL)
-	opts.SetClientID(clientID)
-	opts.AutoReconnect = true // Настроенный реконнект, keep-alive=30 по умолчанию и явно не указываю
-	// Сохранение сессии для целостности отправленных QOS 1,2 сообщений и без повторной подписки
-	opts.CleanSession = false
-	// Логирование потери соединения
-	opts.OnConnectionLost = func(c mqtt.Client, err error) {
-		logger.Log.Warn().
-			Str("component", "mqtt").
-			Err(err).
-			Msg("MQTT connection lost")
+// NewClient подключается к брокеру; onMessage получает время публикации из свойства
+// timestamp MQTT 5 (нулевое время, если его нет) и флаг повторной доставки
+func NewClient(opts Options, onMessage func(string, string, string, time.Time, bool), onMeta func(MetaUpdate)) (*Client, error) {
+	if len(opts.Patterns) == 0 {
+		return nil, fmt.Errorf("no topic patterns configured")
 	}
-	// Логирование установленного соединения
-	opts.OnConnect = func(c mqtt.Client) {
-		logger.Log.Info().
+
+	// Для публикации берем первый шаблон без масок
+	var publishTo *topic.Pattern
+	for _, p := range opts.Patterns {
+		if p.CanFormat() {
+			publishTo = p
+			break
+		}
+	}
+	if publishTo == nil {
+		logger.Log.Warn().
 			Str("component", "mqtt").
-			Msg("MQTT connection established")
+			Msg("No topic pattern usable for publishing, commands will be rejected")
 	}
 
-	if username != "" {
-		opts.SetUsername(username)
+	if opts.Username != "" {
 		logger.Log.Info().Str("component", "mqtt").Msg("Using MQTT username authentication")
 	} else {
 		logger.Log.Info().Str("component", "mqtt").Msg("No MQTT username set, connecting anonymously")
 	}
-
-	if password != "" {
-		opts.SetPassword(password)
-	}
-
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		return nil, token.Error()
+	if opts.TLS != nil {
+		logger.Log.Info().
+			Str("component", "mqtt").
+			Bool("client_certificate", opts.TLS.GetClientCertificate != nil).
+			Bool("insecure_skip_verify", opts.TLS.InsecureSkipVerify).
+			Msg("Using MQTT TLS settings")
 	}
 
 	m := &Client{
-		Client:       client,
-		topics:       topics,
-		subscribeQoS: subscribeQoS,
-		publishQoS:   publishQoS,
+		site:         opts.Site,
+		topics:       opts.Topics,
+		patterns:     opts.Patterns,
+		publishTo:    publishTo,
+		subscribeQoS: opts.SubscribeQoS,
+		publishQoS:   opts.PublishQoS,
 		onMessage:    onMessage,
+		onMeta:       onMeta,
+
+		commandSuffix:  opts.CommandSuffix,
+		commandTimeout: opts.CommandTimeout,
+		commands:       newCommandTracker(),
+	}
+
+	var err error
+	switch opts.ProtocolVersion {
+	case 0, 3:
+		m.conn, err = dialV3(opts, m.handleMessage)
+	case 5:
+		m.contentType = opts.ContentType
+		m.userProperties = opts.UserProperties
+		m.responseTopic = opts.ResponseTopic
+		m.conn, err = dialV5(opts, m.handleMessage)
+	default:
+		return nil, fmt.Errorf("unsupported MQTT protocol version %d", opts.ProtocolVersion)
 	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info().
+		Str("component", "mqtt").
+		Str("broker", opts.BrokerURL).
+		Str("site", opts.Site).
+		Int("protocol_version", max(opts.ProtocolVersion, 3)).
+		Msg("Connected to MQTT broker")
 
-	// Подписываемся на все топики с единым QoS
-	for _, topic := range topics {
-		token := client.Subscribe(topic, subscribeQoS, m.createMessageHandler())
-		if token.Wait() && token.Error() != nil {
+	// Подписываемся на все топики (включая meta) с единым QoS.
+	// Общая подписка делит значения между репликами; meta нужны каждой реплике целиком,
+	// а сохраненные (retained) сообщения по общей подписке не приходят.
+	var subscriptions []string
+	for _, t := range opts.Topics {
+		if opts.SharedGroup != "" {
+			t = "$share/" + opts.SharedGroup + "/" + t
+		}
+		subscriptions = append(subscriptions, t)
+	}
+	if onMeta != nil {
+		subscriptions = append(subscriptions, opts.MetaTopics...)
+	}
+	if m.responseTopic != "" {
+		subscriptions = append(subscriptions, m.responseTopic)
+	}
+	for _, topic := range subscriptions {
+		if err := m.conn.subscribe(topic, opts.SubscribeQoS); err != nil {
 			logger.Log.Error().
 				Str("component", "mqtt").
 				Str("topic", topic).
-				Uint8("qos", subscribeQoS).
-				Err(token.Error()).
+				Uint8("qos", opts.SubscribeQoS).
+				Err(err).
 				Msg("Subscription failed")
 		} else {
 			logger.Log.Info().
 				Str("component", "mqtt").
 				Str("topic", topic).
-				Uint8("qos", subscribeQoS).
+				Uint8("qos", opts.SubscribeQoS).
 				Msg("Subscribed to topic")
 		}
 	}
@@ -95,61 +168,189 @@ func NewClient(
 	return m, nil
 }
 
-func (m *Client) createMessageHandler() mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		t := msg.Topic()
-		if len(t) > 0 && t[0] == '/' {
-			t = t[1:]
+func (m *Client) handleMessage(msg message) {
+	if m.responseTopic != "" && msg.topic == m.responseTopic {
+		m.handleResponse(msg)
+		return
+	}
+
+	// Meta-топики проверяем первыми: шаблоны с "#" могли бы их перехватить
+	if u, ok := parseMetaTopic(msg.topic); ok {
+		m.handleMeta(u, msg)
+		return
+	}
+
+	for _, p := range m.patterns {
+		device, parameter, ok := p.Match(msg.topic)
+		if !ok {
+			continue
 		}
-		parts := splitTopic(t)
-		if len(parts) == 4 && parts[0] == "devices" && parts[2] == "controls" {
-			device := parts[1]
-			parameter := parts[3]
-			value := string(msg.Payload())
+		value := string(msg.payload)
 
-			logger.Log.Debug().
-				Str("component", "mqtt").
-				Str("device", device).
-				Str("parameter", parameter).
-				Str("value", value).
-				Msg("Message received")
+		event := logger.Log.Debug().
+			Str("component", "mqtt").
+			Str("device", device).
+			Str("parameter", parameter).
+			Str("value", value)
+		if msg.contentType != "" {
+			event = event.Str("content_type", msg.contentType)
+		}
+		for _, u := range msg.userProperties {
+			event = event.Str("property_"+u.Key, u.Value)
+		}
+		event.Msg("Message received")
 
-			metrics.MsgReceived.Inc()
-			m.onMessage(device, parameter, value)
-		} else {
-			logger.Log.Warn().
-				Str("component", "mqtt").
-				Str("topic", msg.Topic()).
-				Msg("Received message on unexpected topic")
+		metrics.MsgReceived.Inc()
+		m.commands.confirm(device, parameter, value)
+		m.onMessage(m.qualify(device), parameter, value, msg.sourceTime(), msg.duplicate)
+		return
+	}
+
+	logger.Log.Warn().
+		Str("component", "mqtt").
+		Str("topic", msg.topic).
+		Msg("Received message on unexpected topic")
+}
+
+// qualify добавляет к ID устройства префикс площадки брокера
+func (m *Client) qualify(device string) string {
+	if m.site == "" {
+		return device
+	}
+	return m.site + SiteSeparator + device
+}
+
+// handleResponse завершает команду по ответу MQTT 5: свойство "error" означает отказ
+func (m *Client) handleResponse(msg message) {
+	if len(msg.correlationData) == 0 {
+		logger.Log.Warn().
+			Str("component", "mqtt").
+			Str("topic", msg.topic).
+			Msg("Command response without correlation data")
+		return
+	}
+
+	res := CommandResult{Status: CommandApplied}
+	for _, u := range msg.userProperties {
+		if u.Key == "error" && u.Value != "" {
+			res = CommandResult{Status: CommandRejected, Reason: u.Value}
+			break
+		}
+	}
+	if !m.commands.respond(hex.EncodeToString(msg.correlationData), res) {
+		logger.Log.Debug().
+			Str("component", "mqtt").
+			Str("correlation_data", hex.EncodeToString(msg.correlationData)).
+			Msg("Response for unknown or finished command")
+	}
+}
+
+func (m *Client) handleMeta(u MetaUpdate, msg message) {
+	if m.onMeta == nil {
+		return
+	}
+
+	updates, err := expandMeta(u, msg.payload)
+	if err != nil {
+		logger.Log.Warn().
+			Str("component", "mqtt").
+			Str("topic", msg.topic).
+			Err(err).
+			Msg("Failed to parse meta message")
+		metrics.MsgErrors.Inc()
+		return
+	}
+
+	for _, upd := range updates {
+		logger.Log.Debug().
+			Str("component", "mqtt").
+			Str("device", upd.Device).
+			Str("control", upd.Control).
+			Str("key", upd.Key).
+			Str("value", upd.Value).
+			Msg("Meta received")
+
+		// Ошибка записи (w) означает, что устройство не приняло команду
+		if upd.Control != "" && upd.Key == "error" && strings.Contains(upd.Value, "w") {
+			m.commands.reject(upd.Device, upd.Control, "device reported write error")
 		}
+		upd.Device = m.qualify(upd.Device)
+		m.onMeta(upd)
 	}
 }
 
-func (m *Client) Publish(device, parameter, value string) {
-	topic := fmt.Sprintf("/devices/%s/controls/%s", device, parameter)
-	token := m.Client.Publish(topic, m.publishQoS, false, value)
-	if token.Wait() && token.Error() != nil {
+// SendCommand публикует команду в командный топик контрола (по умолчанию <state>/on)
+// и ждет, пока устройство вернет новое состояние, либо истечет таймаут
+func (m *Client) SendCommand(device, parameter, value string) CommandResult {
+	if m.publishTo == nil {
 		logger.Log.Error().
 			Str("component", "mqtt").
-			Err(token.Error()).
-			Msg("Failed to publish command")
+			Str("device", device).
+			Str("parameter", parameter).
+			Msg("Failed to publish command: no topic pattern for publishing")
 		metrics.MsgErrors.Inc()
-	} else {
-		logger.Log.Info().
+		return CommandResult{Status: CommandRejected, Reason: "no topic pattern for publishing"}
+	}
+
+	pub := publication{
+		topic:          m.publishTo.Format(device, parameter) + m.commandSuffix,
+		qos:            m.publishQoS,
+		payload:        []byte(value),
+		contentType:    m.contentType,
+		userProperties: m.userProperties,
+		// Команда, не доставленная за время ожидания, устройству уже не нужна
+		expiry: m.commandTimeout,
+	}
+	var correlation string
+	if m.responseTopic != "" {
+		pub.responseTopic = m.responseTopic
+		pub.correlationData = newCorrelationData()
+		correlation = hex.EncodeToString(pub.correlationData)
+	}
+	topic := pub.topic
+	pc := m.commands.add(device, parameter, value, correlation)
+
+	if err := m.conn.publish(pub); err != nil {
+		m.commands.remove(device, parameter, pc)
+		logger.Log.Error().
 			Str("component", "mqtt").
 			Str("topic", topic).
-			Uint8("qos", m.publishQoS).
-			Str("value", value).
-			Msg("Published command")
+			Err(err).
+			Msg("Failed to publish command")
+		metrics.MsgErrors.Inc()
+		return CommandResult{Status: CommandRejected, Reason: err.Error()}
 	}
+
+	logger.Log.Info().
+		Str("component", "mqtt").
+		Str("topic", topic).
+		Uint8("qos", m.publishQoS).
+		Str("value", value).
+		Msg("Published command")
+
+	res := m.commands.wait(device, parameter, pc, m.commandTimeout)
+	logger.Log.Info().
+		Str("component", "mqtt").
+		Str("device", device).
+		Str("parameter", parameter).
+		Str("status", res.Status.String()).
+		Str("reason", res.Reason).
+		Msg("Command finished")
+	return res
 }
 
-func splitTopic(t string) []string {
-	var parts []string
-	for _, part := range strings.Split(t, "/") {
-		if part != "" {
-			parts = append(parts, part)
-		}
-	}
-	return parts
+// Close отключается от брокера; после него сообщения больше не передаются обработчикам
+func (m *Client) Close() {
+	m.conn.close()
+	logger.Log.Info().
+		Str("component", "mqtt").
+		Str("site", m.site).
+		Msg("Disconnected from MQTT broker")
+}
+
+// newCorrelationData возвращает случайный идентификатор запроса для correlation data
+func newCorrelationData() []byte {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return b
 }
@@ -0,0 +1,129 @@
+// internal/mqttreceiver/mqtt/router.go
+package mqtt
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+)
+
+// SiteSeparator отделяет префикс площадки от ID устройства: "<site>:<device>"
+const SiteSeparator = ":"
+
+const (
+	// Пауза между попытками подключения к недоступному брокеру; удваивается до connectMaxBackoff
+	connectRetryBackoff = 5 * time.Second
+	connectMaxBackoff   = time.Minute
+)
+
+// Router направляет команды брокеру, с которого пришло устройство
+type Router struct {
+	mu      sync.RWMutex
+	clients map[string]*Client // по площадке; "" — брокер без префикса, nil — еще не подключен
+	closed  bool
+	done    chan struct{}
+}
+
+// NewRouter создает маршрутизатор без клиентов; команды отклоняются, пока клиент не подключен
+func NewRouter() *Router {
+	return &Router{
+		clients: make(map[string]*Client),
+		done:    make(chan struct{}),
+	}
+}
+
+// Connect подключается к брокеру площадки opts.Site в фоне и повторяет попытки,
+// пока брокер недоступен; остальные площадки работают независимо от него
+func (r *Router) Connect(opts Options, onMessage func(string, string, string, time.Time, bool), onMeta func(MetaUpdate)) {
+	r.mu.Lock()
+	r.clients[opts.Site] = nil
+	r.mu.Unlock()
+
+	go r.connect(opts, onMessage, onMeta)
+}
+
+func (r *Router) connect(opts Options, onMessage func(string, string, string, time.Time, bool), onMeta func(MetaUpdate)) {
+	backoff := connectRetryBackoff
+	for {
+		client, err := NewClient(opts, onMessage, onMeta)
+		if err == nil {
+			r.mu.Lock()
+			if r.closed {
+				r.mu.Unlock()
+				client.Close()
+				return
+			}
+			r.clients[opts.Site] = client
+			r.mu.Unlock()
+			return
+		}
+
+		logger.Log.Error().
+			Str("component", "mqtt").
+			Str("broker", opts.BrokerURL).
+			Str("site", opts.Site).
+			Dur("retry_in", backoff).
+			Err(err).
+			Msg("MQTT client init failed")
+		select {
+		case <-time.After(backoff):
+		case <-r.done:
+			return
+		}
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
+
+// Close прекращает подключения и отключает всех клиентов
+func (r *Router) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.done)
+	clients := r.clients
+	r.clients = make(map[string]*Client)
+	r.mu.Unlock()
+
+	for _, c := range clients {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+// SendCommand отправляет команду через брокер площадки из префикса устройства
+func (r *Router) SendCommand(device, parameter, value string) CommandResult {
+	client, local, reason := r.route(device)
+	if client == nil {
+		return CommandResult{Status: CommandRejected, Reason: reason}
+	}
+	return client.SendCommand(local, parameter, value)
+}
+
+// route возвращает клиента и ID устройства без префикса площадки либо причину отказа
+func (r *Router) route(device string) (*Client, string, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if site, local, ok := strings.Cut(device, SiteSeparator); ok {
+		if c, known := r.clients[site]; known {
+			if c == nil {
+				return nil, "", "MQTT broker of site " + site + " is not connected"
+			}
+			return c, local, ""
+		}
+	}
+	c, known := r.clients[""]
+	switch {
+	case c != nil:
+		return c, device, ""
+	case known:
+		return nil, "", "MQTT broker is not connected"
+	}
+	return nil, "", "no MQTT broker for device " + device
+}
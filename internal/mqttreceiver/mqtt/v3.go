@@ -0,0 +1,75 @@
+// internal/mqttreceiver/mqtt/v3.go
+package mqtt
+
+import (
+	"brutus/internal/mqttreceiver/logger"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// v3Conn — подключение по MQTT 3.1.1 через paho.mqtt.golang
+type v3Conn struct {
+	client  mqtt.Client
+	handler mqtt.MessageHandler
+}
+
+func dialV3(o Options, handle func(message)) (*v3Conn, error) {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(o.BrokerURL)
+	opts.SetClientID(o.ClientID)
+	opts.AutoReconnect = true // Настроенный реконнект, keep-alive=30 по умолчанию и явно не указываю
+	// Сохранение сессии для целостности отправленных QOS 1,2 сообщений и без повторной подписки
+	opts.CleanSession = false
+	// Логирование потери соединения
+	opts.OnConnectionLost = func(c mqtt.Client, err error) {
+		logger.Log.Warn().
+			Str("component", "mqtt").
+			Err(err).
+			Msg("MQTT connection lost")
+	}
+	// Логирование установленного соединения
+	opts.OnConnect = func(c mqtt.Client) {
+		logger.Log.Info().
+			Str("component", "mqtt").
+			Msg("MQTT connection established")
+	}
+
+	if o.Username != "" {
+		opts.SetUsername(o.Username)
+	}
+	if o.Password != "" {
+		opts.SetPassword(o.Password)
+	}
+	// Используется для ssl://, tls://, mqtts:// и wss://; nil — проверка по системным CA
+	if o.TLS != nil {
+		opts.SetTLSConfig(o.TLS)
+	}
+
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &v3Conn{
+		client: client,
+		handler: func(c mqtt.Client, msg mqtt.Message) {
+			handle(message{topic: msg.Topic(), payload: msg.Payload(), duplicate: msg.Duplicate()})
+		},
+	}, nil
+}
+
+func (c *v3Conn) subscribe(filter string, qos byte) error {
+	token := c.client.Subscribe(filter, qos, c.handler)
+	token.Wait()
+	return token.Error()
+}
+
+func (c *v3Conn) publish(p publication) error {
+	token := c.client.Publish(p.topic, p.qos, false, p.payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (c *v3Conn) close() {
+	c.client.Disconnect(250)
+}
@@ -0,0 +1,191 @@
+// internal/mqttreceiver/mqtt/v5.go
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+const (
+	// Сессия переживает обрывы связи, как CleanSession=false в 3.1.1, но не хранится вечно
+	v5SessionExpiry = 24 * time.Hour
+	// Сколько ждать первого подключения, прежде чем считать брокер недоступным
+	v5ConnectTimeout = 30 * time.Second
+	// Таймаут одной операции подписки или публикации
+	v5RequestTimeout = 30 * time.Second
+)
+
+type v5Subscription struct {
+	filter string
+	qos    byte
+}
+
+// v5Conn — подключение по MQTT 5 через paho.golang с автоматическим переподключением
+type v5Conn struct {
+	cm *autopaho.ConnectionManager
+
+	// Подписки повторяются после переподключения: сессия на брокере могла истечь
+	mu            sync.Mutex
+	subscriptions []v5Subscription
+}
+
+func dialV5(o Options, handle func(message)) (*v5Conn, error) {
+	brokerURL, err := url.Parse(o.BrokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker URL: %v", err)
+	}
+
+	c := &v5Conn{}
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{brokerURL},
+		TlsCfg:                        o.TLS,
+		KeepAlive:                     30,
+		CleanStartOnInitialConnection: false,
+		SessionExpiryInterval:         uint32(v5SessionExpiry.Seconds()),
+		ConnectUsername:               o.Username,
+		ConnectPassword:               []byte(o.Password),
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, _ *paho.Connack) {
+			logger.Log.Info().
+				Str("component", "mqtt").
+				Msg("MQTT connection established")
+			go c.resubscribe()
+		},
+		OnConnectionDown: func() bool {
+			logger.Log.Warn().
+				Str("component", "mqtt").
+				Msg("MQTT connection lost")
+			return true
+		},
+		OnConnectError: func(err error) {
+			logger.Log.Warn().
+				Str("component", "mqtt").
+				Err(err).
+				Msg("MQTT connection attempt failed")
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: o.ClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					handle(v5Message(pr.Packet))
+					return true, nil
+				},
+			},
+		},
+	}
+
+	c.cm, err = autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v5ConnectTimeout)
+	defer cancel()
+	if err := c.cm.AwaitConnection(ctx); err != nil {
+		_ = c.cm.Disconnect(context.Background())
+		return nil, fmt.Errorf("no connection to %s within %s", o.BrokerURL, v5ConnectTimeout)
+	}
+	return c, nil
+}
+
+func v5Message(p *paho.Publish) message {
+	msg := message{topic: p.Topic, payload: p.Payload, duplicate: p.Duplicate()}
+	if p.Properties != nil {
+		msg.contentType = p.Properties.ContentType
+		msg.correlationData = p.Properties.CorrelationData
+		for _, u := range p.Properties.User {
+			msg.userProperties = append(msg.userProperties, UserProperty{Key: u.Key, Value: u.Value})
+		}
+	}
+	return msg
+}
+
+func (c *v5Conn) subscribe(filter string, qos byte) error {
+	c.mu.Lock()
+	c.subscriptions = append(c.subscriptions, v5Subscription{filter: filter, qos: qos})
+	c.mu.Unlock()
+	return c.sendSubscribe([]v5Subscription{{filter: filter, qos: qos}})
+}
+
+// resubscribe повторяет подписки после переподключения
+func (c *v5Conn) resubscribe() {
+	c.mu.Lock()
+	subs := append([]v5Subscription{}, c.subscriptions...)
+	c.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	if err := c.sendSubscribe(subs); err != nil {
+		logger.Log.Error().
+			Str("component", "mqtt").
+			Err(err).
+			Msg("Resubscription failed")
+	}
+}
+
+func (c *v5Conn) sendSubscribe(subs []v5Subscription) error {
+	req := &paho.Subscribe{}
+	for _, s := range subs {
+		req.Subscriptions = append(req.Subscriptions, paho.SubscribeOptions{Topic: s.filter, QoS: s.qos})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v5RequestTimeout)
+	defer cancel()
+	ack, err := c.cm.Subscribe(ctx, req)
+	if err != nil {
+		return err
+	}
+	for i, code := range ack.Reasons {
+		// Коды 0x00–0x02 — выданный QoS, от 0x80 — отказ
+		if code >= 0x80 && i < len(subs) {
+			return fmt.Errorf("subscription to %s refused with reason code 0x%02x", subs[i].filter, code)
+		}
+	}
+	return nil
+}
+
+func (c *v5Conn) publish(p publication) error {
+	props := &paho.PublishProperties{
+		ContentType:     p.contentType,
+		ResponseTopic:   p.responseTopic,
+		CorrelationData: p.correlationData,
+	}
+	for _, u := range p.userProperties {
+		props.User = append(props.User, paho.UserProperty{Key: u.Key, Value: u.Value})
+	}
+	if p.expiry > 0 {
+		expiry := uint32(min(math.Ceil(p.expiry.Seconds()), math.MaxUint32))
+		props.MessageExpiry = &expiry
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v5RequestTimeout)
+	defer cancel()
+	resp, err := c.cm.Publish(ctx, &paho.Publish{
+		Topic:      p.topic,
+		QoS:        p.qos,
+		Payload:    p.payload,
+		Properties: props,
+	})
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.ReasonCode >= 0x80 {
+		return fmt.Errorf("publish refused with reason code 0x%02x", resp.ReasonCode)
+	}
+	return nil
+}
+
+func (c *v5Conn) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), v5RequestTimeout)
+	defer cancel()
+	_ = c.cm.Disconnect(ctx)
+}
@@ -0,0 +1,94 @@
+// internal/mqttreceiver/policy/pattern.go
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Pattern — glob-маски устройства и параметра, записывается как "wb-adc/*" или "*/uptime".
+// Маска без "/" относится ко всем параметрам устройства.
+type Pattern struct {
+	Device    string
+	Parameter string
+}
+
+// ParsePattern разбирает маску вида "device/parameter"
+func ParsePattern(s string) (Pattern, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Pattern{}, fmt.Errorf("empty pattern")
+	}
+
+	p := Pattern{Device: s, Parameter: "*"}
+	if i := strings.Index(s, "/"); i >= 0 {
+		p.Device, p.Parameter = s[:i], s[i+1:]
+	}
+	for _, part := range []string{p.Device, p.Parameter} {
+		if part == "" {
+			return Pattern{}, fmt.Errorf("pattern %q: empty device or parameter", s)
+		}
+		if _, err := path.Match(part, ""); err != nil {
+			return Pattern{}, fmt.Errorf("pattern %q: %v", s, err)
+		}
+	}
+	return p, nil
+}
+
+// ParsePatterns разбирает список масок, разделенных запятыми
+func ParsePatterns(s string) ([]Pattern, error) {
+	var patterns []Pattern
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		p, err := ParsePattern(item)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Match проверяет, подходит ли ряд под маску
+func (p Pattern) Match(device, parameter string) bool {
+	ok, _ := path.Match(p.Device, device)
+	if !ok {
+		return false
+	}
+	ok, _ = path.Match(p.Parameter, parameter)
+	return ok
+}
+
+func (p Pattern) String() string {
+	return p.Device + "/" + p.Parameter
+}
+
+// MatchAny проверяет ряд по списку масок
+func MatchAny(patterns []Pattern, device, parameter string) bool {
+	for _, p := range patterns {
+		if p.Match(device, parameter) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRules разбивает строку "маска=значение; маска=значение" на пары
+func splitRules(s string) ([][2]string, error) {
+	var rules [][2]string
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q: expected pattern=value", item)
+		}
+		rules = append(rules, [2]string{strings.TrimSpace(pattern), strings.TrimSpace(value)})
+	}
+	return rules, nil
+}
@@ -0,0 +1,82 @@
+// internal/mqttreceiver/policy/recording.go
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recording — правило записи ряда в историю
+type Recording struct {
+	Pattern
+	OnChange    bool          // писать только изменившиеся значения
+	Deadband    float64       // абсолютная зона нечувствительности для чисел
+	DeadbandPct float64       // зона нечувствительности в процентах от последнего записанного
+	MinInterval time.Duration // писать не чаще, чем раз в интервал
+	Heartbeat   time.Duration // писать не реже, чем раз в интервал, даже без изменений
+}
+
+// RecordingRules — правила записи; применяется первое подходящее
+type RecordingRules []Recording
+
+// ParseRecording разбирает правила вида
+// "wb-adc/*=deadband:0.05,min_interval:10s,heartbeat:5m; */uptime=on_change; meter/*=deadband_pct:1"
+func ParseRecording(s string) (RecordingRules, error) {
+	pairs, err := splitRules(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules RecordingRules
+	for _, pair := range pairs {
+		p, err := ParsePattern(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		r := Recording{Pattern: p}
+
+		for _, opt := range strings.Split(pair[1], ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), ":")
+			switch key {
+			case "on_change":
+				r.OnChange = true
+			case "deadband", "deadband_pct":
+				f, err := strconv.ParseFloat(value, 64)
+				if err != nil || f < 0 {
+					return nil, fmt.Errorf("rule %q: invalid %s %q", pair[0], key, value)
+				}
+				if key == "deadband" {
+					r.Deadband = f
+				} else {
+					r.DeadbandPct = f
+				}
+			case "min_interval", "heartbeat":
+				d, err := time.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("rule %q: invalid %s %q", pair[0], key, value)
+				}
+				if key == "min_interval" {
+					r.MinInterval = d
+				} else {
+					r.Heartbeat = d
+				}
+			default:
+				return nil, fmt.Errorf("rule %q: unknown option %q", pair[0], key)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Lookup возвращает первое правило, подходящее под ряд
+func (rs RecordingRules) Lookup(device, parameter string) (Recording, bool) {
+	for _, r := range rs {
+		if r.Match(device, parameter) {
+			return r, true
+		}
+	}
+	return Recording{}, false
+}
@@ -0,0 +1,57 @@
+// internal/mqttreceiver/policy/recording_test.go
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecording(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    RecordingRules
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"wb-adc/*=deadband:0.05,min_interval:10s,heartbeat:5m; */uptime=on_change; meter/*=deadband_pct:1", RecordingRules{
+			{Pattern: Pattern{"wb-adc", "*"}, Deadband: 0.05, MinInterval: 10 * time.Second, Heartbeat: 5 * time.Minute},
+			{Pattern: Pattern{"*", "uptime"}, OnChange: true},
+			{Pattern: Pattern{"meter", "*"}, DeadbandPct: 1},
+		}, false},
+		{"wb-adc = on_change , deadband:0", RecordingRules{{Pattern: Pattern{"wb-adc", "*"}, OnChange: true}}, false},
+		{"wb-adc/*", nil, true},
+		{"wb-adc/*=", nil, true},
+		{"wb-adc/*=on_chnage", nil, true},
+		{"wb-adc/*=deadband", nil, true},
+		{"wb-adc/*=deadband:-1", nil, true},
+		{"wb-adc/*=deadband_pct:x", nil, true},
+		{"wb-adc/*=min_interval:10", nil, true},
+		{"wb-adc/*=heartbeat:0s", nil, true},
+		{"/*=on_change", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRecording(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRecording(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRecording(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+// Применяется первое подходящее правило, даже если ниже есть более точное
+func TestRecordingLookup(t *testing.T) {
+	rules, err := ParseRecording("wb-adc/*=on_change; wb-adc/A1=deadband:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := rules.Lookup("wb-adc", "A1"); !ok || !got.OnChange || got.Deadband != 0 {
+		t.Errorf("Lookup(wb-adc, A1) = %+v, %v, want the on_change rule", got, ok)
+	}
+	if _, ok := rules.Lookup("wb-gpio", "A1"); ok {
+		t.Error("Lookup matched a series without a rule")
+	}
+}
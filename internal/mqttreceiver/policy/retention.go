@@ -0,0 +1,60 @@
+// internal/mqttreceiver/policy/retention.go
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Retention — срок хранения истории для рядов, подходящих под маску
+type Retention struct {
+	Pattern
+	Days    int  // срок хранения в днях
+	Forever bool // не удалять никогда
+	Skip    bool // не писать в историю вовсе, обновляется только текущее значение
+}
+
+// RetentionRules — правила хранения; применяется первое подходящее
+type RetentionRules []Retention
+
+// ParseRetention разбирает правила вида "system/uptime=none; energy/*=forever; wb-adc/*=30d"
+func ParseRetention(s string) (RetentionRules, error) {
+	pairs, err := splitRules(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules RetentionRules
+	for _, pair := range pairs {
+		p, err := ParsePattern(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		r := Retention{Pattern: p}
+		switch value := strings.ToLower(pair[1]); value {
+		case "forever":
+			r.Forever = true
+		case "none":
+			r.Skip = true
+		default:
+			days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+			if err != nil || days < 1 {
+				return nil, fmt.Errorf("rule %q: retention must be <days>d, forever or none", pair[0])
+			}
+			r.Days = days
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Lookup возвращает первое правило, подходящее под ряд
+func (rs RetentionRules) Lookup(device, parameter string) (Retention, bool) {
+	for _, r := range rs {
+		if r.Match(device, parameter) {
+			return r, true
+		}
+	}
+	return Retention{}, false
+}
@@ -0,0 +1,117 @@
+// internal/mqttreceiver/policy/retention_test.go
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Pattern
+		wantErr bool
+	}{
+		{"wb-adc/*", Pattern{"wb-adc", "*"}, false},
+		{" */uptime ", Pattern{"*", "uptime"}, false},
+		{"wb-adc", Pattern{"wb-adc", "*"}, false},
+		{"wb-mr6c_*/K[1-3]", Pattern{"wb-mr6c_*", "K[1-3]"}, false},
+		{"a/b/c", Pattern{"a", "b/c"}, false},
+		{"", Pattern{}, true},
+		{"/uptime", Pattern{}, true},
+		{"wb-adc/", Pattern{}, true},
+		{"wb-adc/[", Pattern{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePattern(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePattern(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePattern(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern   Pattern
+		device    string
+		parameter string
+		want      bool
+	}{
+		{Pattern{"wb-adc", "*"}, "wb-adc", "A1", true},
+		{Pattern{"wb-adc", "*"}, "wb-adc2", "A1", false},
+		{Pattern{"*", "uptime"}, "system", "uptime", true},
+		{Pattern{"*", "uptime"}, "system", "uptime2", false},
+		{Pattern{"wb-mr6c_*", "K?"}, "wb-mr6c_12", "K1", true},
+		{Pattern{"wb-mr6c_*", "K?"}, "wb-mr6c_12", "K10", false},
+		{Pattern{"*", "*"}, "", "", true},
+		// "*" не захватывает "/" внутри имени
+		{Pattern{"*", "*"}, "a/b", "c", false},
+		{Pattern{"a", "b/c"}, "a", "b/c", true},
+	}
+	for _, tt := range tests {
+		if got := tt.pattern.Match(tt.device, tt.parameter); got != tt.want {
+			t.Errorf("%v.Match(%q, %q) = %v, want %v", tt.pattern, tt.device, tt.parameter, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    RetentionRules
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"system/uptime=none; energy/*=forever; wb-adc/*=30d", RetentionRules{
+			{Pattern: Pattern{"system", "uptime"}, Skip: true},
+			{Pattern: Pattern{"energy", "*"}, Forever: true},
+			{Pattern: Pattern{"wb-adc", "*"}, Days: 30},
+		}, false},
+		{" wb-adc = 7 ;; ", RetentionRules{{Pattern: Pattern{"wb-adc", "*"}, Days: 7}}, false},
+		{"*/*=FOREVER", RetentionRules{{Pattern: Pattern{"*", "*"}, Forever: true}}, false},
+		{"wb-adc/*", nil, true},
+		{"wb-adc/*=0d", nil, true},
+		{"wb-adc/*=-1d", nil, true},
+		{"wb-adc/*=1w", nil, true},
+		{"wb-adc/*=", nil, true},
+		{"=30d", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetention(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRetention(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRetention(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRetentionLookup(t *testing.T) {
+	rules, err := ParseRetention("wb-adc/A1=none; wb-adc/*=30d; */*=forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		device, parameter string
+		want              Retention
+	}{
+		{"wb-adc", "A1", rules[0]},
+		{"wb-adc", "A2", rules[1]},
+		{"system", "uptime", rules[2]},
+	}
+	for _, tt := range tests {
+		got, ok := rules.Lookup(tt.device, tt.parameter)
+		if !ok || got != tt.want {
+			t.Errorf("Lookup(%q, %q) = %+v, %v, want %+v", tt.device, tt.parameter, got, ok, tt.want)
+		}
+	}
+	if _, ok := rules[:2].Lookup("system", "uptime"); ok {
+		t.Error("Lookup matched a series without a rule")
+	}
+}
@@ -0,0 +1,342 @@
+// internal/mqttreceiver/sink/influx.go
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/metrics"
+	"brutus/internal/mqttreceiver/storage"
+)
+
+const (
+	influxMaxBackoff   = time.Minute
+	influxSendAttempts = 3
+)
+
+// errInfluxRejected — сервер отклонил пачку (4xx, кроме 429): повтор ее не исправит
+var errInfluxRejected = errors.New("influx rejected batch")
+
+// InfluxConfig — параметры отправки в InfluxDB
+type InfluxConfig struct {
+	URL           string // полный URL записи, например http://influx:8086/api/v2/write?org=o&bucket=b
+	Token         string
+	Measurement   string
+	BatchSize     int
+	FlushInterval time.Duration
+	SpoolFile     string // файл для строк, которые не удалось отправить
+	SpoolMaxBytes int64
+}
+
+// InfluxSink пересылает значения в InfluxDB line protocol пачками.
+// Если сервер недоступен, пачки откладываются в spool-файл и отправляются позже.
+type InfluxSink struct {
+	cfg    InfluxConfig
+	client *http.Client
+	lines  chan string
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	spoolMu sync.Mutex
+}
+
+// NewInfluxSink запускает фоновую отправку
+func NewInfluxSink(cfg InfluxConfig) *InfluxSink {
+	s := &InfluxSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		lines:  make(chan string, cfg.BatchSize*10),
+		done:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Write переводит значение в line protocol; числа пишутся в поле value, остальное — в value_str
+func (s *InfluxSink) Write(device, parameter, value string, ts time.Time) {
+	line := s.formatLine(device, parameter, value, ts)
+	select {
+	case s.lines <- line:
+	default:
+		// Очередь полна — сервер не успевает, сразу откладываем в spool
+		s.spool([]string{line})
+	}
+}
+
+func (s *InfluxSink) formatLine(device, parameter, value string, ts time.Time) string {
+	var b strings.Builder
+	b.WriteString(escapeMeasurement(s.cfg.Measurement))
+	b.WriteString(",device=")
+	b.WriteString(escapeTag(device))
+	b.WriteString(",control=")
+	b.WriteString(escapeTag(parameter))
+
+	if _, num := storage.ClassifyValue(value, ""); num != nil {
+		b.WriteString(" value=")
+		b.WriteString(strconv.FormatFloat(*num, 'g', -1, 64))
+	} else {
+		b.WriteString(` value_str="`)
+		b.WriteString(fieldEscaper.Replace(value))
+		b.WriteString(`"`)
+	}
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+	return b.String()
+}
+
+func (s *InfluxSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, s.cfg.BatchSize)
+	for {
+		select {
+		case line := <-s.lines:
+			batch = append(batch, line)
+			if len(batch) >= s.cfg.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.flush(batch)
+			batch = batch[:0]
+		case <-s.done:
+			// Все, что не успели отправить, остается в spool до следующего запуска
+			for {
+				select {
+				case line := <-s.lines:
+					batch = append(batch, line)
+				default:
+					s.spool(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush сначала досылает отложенное, затем текущую пачку
+func (s *InfluxSink) flush(batch []string) {
+	if !s.replaySpool() {
+		s.spool(batch)
+		return
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := s.sendWithRetry(batch); errors.Is(err, errInfluxRejected) {
+		s.rejected(len(batch), err)
+	} else if err != nil {
+		logger.Log.Warn().
+			Str("component", "influxSink").
+			Int("points", len(batch)).
+			Err(err).
+			Msg("InfluxDB unavailable, spooling batch")
+		s.spool(batch)
+	}
+}
+
+// sendWithRetry отправляет пачку, повторяя с экспоненциальной задержкой
+func (s *InfluxSink) sendWithRetry(batch []string) error {
+	backoff := time.Second
+	var err error
+	for attempt := 1; attempt <= influxSendAttempts; attempt++ {
+		if err = s.send(batch); err == nil {
+			metrics.SinkPointsSent.Add(float64(len(batch)))
+			return nil
+		}
+		metrics.SinkErrors.Inc()
+		if attempt == influxSendAttempts || errors.Is(err, errInfluxRejected) {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.done:
+			return err
+		}
+		backoff = min(backoff*2, influxMaxBackoff)
+	}
+	return err
+}
+
+func (s *InfluxSink) send(batch []string) error {
+	body := strings.Join(batch, "\n")
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("influx write failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: %v", errInfluxRejected, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// rejected считает отклоненные сервером точки потерянными
+func (s *InfluxSink) rejected(points int, err error) {
+	metrics.SinkDropped.Add(float64(points))
+	logger.Log.Error().
+		Str("component", "influxSink").
+		Int("points", points).
+		Err(err).
+		Msg("InfluxDB rejected batch, dropping points")
+}
+
+// spool дописывает строки в файл отложенной отправки
+func (s *InfluxSink) spool(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	s.spoolMu.Lock()
+	defer s.spoolMu.Unlock()
+
+	if spoolSize(s.cfg.SpoolFile)+spoolSize(s.replayFile()) >= s.cfg.SpoolMaxBytes {
+		metrics.SinkDropped.Add(float64(len(lines)))
+		logger.Log.Warn().
+			Str("component", "influxSink").
+			Int("points", len(lines)).
+			Msg("Spool file full, dropping points")
+		return
+	}
+
+	f, err := os.OpenFile(s.cfg.SpoolFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		metrics.SinkDropped.Add(float64(len(lines)))
+		logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to open spool file")
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		metrics.SinkDropped.Add(float64(len(lines)))
+		logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to write spool file")
+		return
+	}
+	metrics.SinkSpooled.Add(float64(len(lines)))
+}
+
+// replayFile — отложенные строки, которые отправляются сейчас; spool тем временем
+// принимает новые, и Write не ждет медленного сервера
+func (s *InfluxSink) replayFile() string {
+	return s.cfg.SpoolFile + ".replay"
+}
+
+// replaySpool отправляет отложенные строки; false — сервер все еще недоступен.
+// Под spoolMu только забираем spool-файл, отправка идет без блокировки.
+func (s *InfluxSink) replaySpool() bool {
+	replay := s.replayFile()
+	if _, err := os.Stat(replay); os.IsNotExist(err) {
+		s.spoolMu.Lock()
+		err := os.Rename(s.cfg.SpoolFile, replay)
+		s.spoolMu.Unlock()
+		if os.IsNotExist(err) {
+			return true
+		}
+		if err != nil {
+			logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to take spool file")
+			return true
+		}
+	}
+
+	f, err := os.Open(replay)
+	if err != nil {
+		logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to open spool file")
+		return true
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to read spool file")
+	}
+
+	for len(lines) > 0 {
+		n := min(len(lines), s.cfg.BatchSize)
+		if err := s.send(lines[:n]); errors.Is(err, errInfluxRejected) {
+			metrics.SinkErrors.Inc()
+			s.rejected(n, err)
+		} else if err != nil {
+			metrics.SinkErrors.Inc()
+			// Оставляем в файле только неотправленное
+			if werr := os.WriteFile(replay, []byte(strings.Join(lines, "\n")+"\n"), 0o644); werr != nil {
+				logger.Log.Error().Str("component", "influxSink").Err(werr).Msg("Failed to rewrite spool file")
+			}
+			return false
+		} else {
+			metrics.SinkPointsSent.Add(float64(n))
+		}
+		lines = lines[n:]
+	}
+
+	logger.Log.Info().Str("component", "influxSink").Msg("Spooled points delivered")
+	if err := os.Remove(replay); err != nil && !os.IsNotExist(err) {
+		logger.Log.Error().Str("component", "influxSink").Err(err).Msg("Failed to remove spool file")
+	}
+	return true
+}
+
+// Close останавливает отправку и откладывает неотправленное в spool
+func (s *InfluxSink) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
+
+// Экранирование имени измерения и тегов line protocol. Обратный слеш экранируется, иначе
+// слеш в конце значения съел бы следующий разделитель. Перевод строки разделяет записи
+// и не экранируется вовсе — заменяем его пробелом.
+var (
+	measurementEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, ` `, `\ `, "\n", `\ `, "\r", `\ `)
+	tagEscaper         = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\ `, "\r", `\ `)
+	fieldEscaper       = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func escapeMeasurement(s string) string {
+	return measurementEscaper.Replace(s)
+}
+
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+func spoolSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
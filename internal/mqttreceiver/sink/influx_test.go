@@ -0,0 +1,44 @@
+// internal/mqttreceiver/sink/influx_test.go
+package sink
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLine(t *testing.T) {
+	s := &InfluxSink{cfg: InfluxConfig{Measurement: "mqtt"}}
+	ts := time.Unix(1700000000, 5)
+	tests := []struct {
+		name      string
+		device    string
+		parameter string
+		value     string
+		want      string
+	}{
+		{"number", "wb-adc", "A1", "21.5", `mqtt,device=wb-adc,control=A1 value=21.5 1700000000000000005`},
+		{"string", "wb-gpio", "A1_OUT", "hello", `mqtt,device=wb-gpio,control=A1_OUT value_str="hello" 1700000000000000005`},
+		{"tag separators", "room 1,a=b", "t", "1", `mqtt,device=room\ 1\,a\=b,control=t value=1 1700000000000000005`},
+		{"trailing backslash", `dev\`, `ctl\`, "1", `mqtt,device=dev\\,control=ctl\\ value=1 1700000000000000005`},
+		{"newline in tag", "dev\nx", "ctl\r\ny", "1", `mqtt,device=dev\ x,control=ctl\ \ y value=1 1700000000000000005`},
+		{"string escapes", "d", "c", "say \"hi\"\\\nbye", `mqtt,device=d,control=c value_str="say \"hi\"\\\nbye" 1700000000000000005`},
+	}
+	for _, tt := range tests {
+		got := s.formatLine(tt.device, tt.parameter, tt.value, ts)
+		if got != tt.want {
+			t.Errorf("%s: formatLine = %q, want %q", tt.name, got, tt.want)
+		}
+		if strings.ContainsAny(got, "\r\n") {
+			t.Errorf("%s: line %q contains a line break", tt.name, got)
+		}
+	}
+}
+
+func TestEscapeMeasurement(t *testing.T) {
+	s := &InfluxSink{cfg: InfluxConfig{Measurement: "my mqtt,x\\"}}
+	got := s.formatLine("d", "c", "1", time.Unix(0, 1))
+	if want := `my\ mqtt\,x\\,device=d,control=c value=1 1`; got != want {
+		t.Errorf("formatLine = %q, want %q", got, want)
+	}
+}
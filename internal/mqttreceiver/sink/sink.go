@@ -0,0 +1,11 @@
+// internal/mqttreceiver/sink/sink.go
+package sink
+
+import "time"
+
+// Sink — внешний приемник значений, в который пересылается все записанное в хранилище
+type Sink interface {
+	// Write ставит значение в очередь на отправку и не блокирует воркер приема
+	Write(device, parameter, value string, ts time.Time)
+	Close() error
+}
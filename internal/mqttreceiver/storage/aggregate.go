@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Агрегатные функции для прореженной истории
+type Aggregate string
+
+const (
+	AggregateMin   Aggregate = "min"
+	AggregateMax   Aggregate = "max"
+	AggregateAvg   Aggregate = "avg"
+	AggregateFirst Aggregate = "first"
+	AggregateLast  Aggregate = "last"
+	AggregateCount Aggregate = "count"
+	AggregateSum   Aggregate = "sum"
+)
+
+// Максимальное число корзин в одном запросе, чтобы не собрать в памяти слишком много
+const MaxAggregateBuckets = 100000
+
+// Bucket — агрегаты по одному интервалу времени.
+// Count учитывает все строки, остальные поля — только числовые (NumCount).
+type Bucket struct {
+	Start    time.Time
+	Count    int64
+	NumCount int64
+	Min      float64
+	Max      float64
+	Sum      float64
+	First    float64
+	Last     float64
+}
+
+// Value возвращает значение агрегата; false, если в корзине нет числовых значений
+func (b *Bucket) Value(fn Aggregate) (float64, bool) {
+	if fn == AggregateCount {
+		return float64(b.Count), true
+	}
+	if b.NumCount == 0 {
+		return 0, false
+	}
+	switch fn {
+	case AggregateMin:
+		return b.Min, true
+	case AggregateMax:
+		return b.Max, true
+	case AggregateAvg:
+		return b.Sum / float64(b.NumCount), true
+	case AggregateFirst:
+		return b.First, true
+	case AggregateLast:
+		return b.Last, true
+	case AggregateSum:
+		return b.Sum, true
+	}
+	return 0, false
+}
+
+// add учитывает одно значение; значения должны приходить в хронологическом порядке
+func (b *Bucket) add(num *float64) {
+	b.Count++
+	if num == nil {
+		return
+	}
+	v := *num
+	if b.NumCount == 0 {
+		b.Min, b.Max, b.First = v, v, v
+	}
+	b.Min = min(b.Min, v)
+	b.Max = max(b.Max, v)
+	b.Sum += v
+	b.Last = v
+	b.NumCount++
+}
+
+// merge добавляет корзину более мелкого уровня; корзины должны идти в хронологическом порядке
+func (b *Bucket) merge(o Bucket) {
+	b.Count += o.Count
+	if o.NumCount == 0 {
+		return
+	}
+	if b.NumCount == 0 {
+		b.Min, b.Max, b.First = o.Min, o.Max, o.First
+	}
+	b.Min = min(b.Min, o.Min)
+	b.Max = max(b.Max, o.Max)
+	b.Sum += o.Sum
+	b.Last = o.Last
+	b.NumCount += o.NumCount
+}
+
+// bucketStart выравнивает время по границе корзины относительно Unix epoch,
+// чтобы границы не зависели от начала запрошенного периода
+func bucketStart(t time.Time, size time.Duration) time.Time {
+	ms := t.UnixMilli()
+	step := size.Milliseconds()
+	start := ms - ms%step
+	if ms < 0 && ms%step != 0 {
+		start -= step
+	}
+	return time.UnixMilli(start).UTC()
+}
+
+// ValidateAggregateRange проверяет размер корзины и период запроса прореженной истории
+func ValidateAggregateRange(startMs, endMs int64, bucket time.Duration) error {
+	if bucket < time.Millisecond {
+		return fmt.Errorf("bucket size must be at least 1ms")
+	}
+	if endMs < startMs {
+		return fmt.Errorf("end timestamp is before start timestamp")
+	}
+	if (endMs-startMs)/bucket.Milliseconds() > MaxAggregateBuckets {
+		return fmt.Errorf("too many buckets, max %d", MaxAggregateBuckets)
+	}
+	return nil
+}
+
+// GetAggregatedHistory возвращает историю, прореженную до корзин размером bucket.
+// Начало периода выравнивается вниз по границе корзины, так что первая корзина всегда полная.
+// Если размер корзины кратен одному из уровней rollup, посчитанная часть берется из него,
+// а хвост, который еще не агрегирован, — из сырой истории.
+func (db *DB) GetAggregatedHistory(device, parameter string, startMs, endMs int64, bucket time.Duration) ([]Bucket, error) {
+	if err := ValidateAggregateRange(startMs, endMs, bucket); err != nil {
+		return nil, err
+	}
+
+	startTime := bucketStart(time.UnixMilli(startMs), bucket)
+	endTime := time.UnixMilli(endMs).UTC().Truncate(time.Millisecond)
+
+	var buckets []Bucket
+	if tier := db.rollupTierFor(bucket, startTime, time.Now()); tier != nil {
+		rolled, mark, err := db.aggregateRollups(tier, device, parameter, startTime, endTime, bucket)
+		if err != nil {
+			return nil, err
+		}
+		buckets = rolled
+		if mark.After(startTime) {
+			startTime = mark
+		}
+	}
+
+	raw, err := db.aggregateRaw(device, parameter, startTime, endTime, bucket)
+	if err != nil {
+		return nil, err
+	}
+	// Корзина на границе уровня и сырой истории может быть общей
+	if len(raw) > 0 && len(buckets) > 0 && buckets[len(buckets)-1].Start.Equal(raw[0].Start) {
+		buckets[len(buckets)-1].merge(raw[0])
+		raw = raw[1:]
+	}
+	return append(buckets, raw...), nil
+}
+
+// aggregateRaw агрегирует сырую историю; строки читаются курсором, в памяти держатся только корзины.
+// В TimescaleDB агрегация выполняется на стороне БД.
+func (db *DB) aggregateRaw(device, parameter string, startTime, endTime time.Time, bucket time.Duration) ([]Bucket, error) {
+	if db.dialect == DialectTimescale {
+		return db.aggregateTimescale(device, parameter, startTime, endTime, bucket)
+	}
+
+	rows, err := db.Conn.Model(&History{}).
+		Select("timestamp, num_value").
+		Where("device = ? AND parameter = ? AND timestamp BETWEEN ? AND ?",
+			device, parameter, startTime, endTime).
+		Order("timestamp ASC").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var (
+			ts  time.Time
+			num *float64
+		)
+		if err := rows.Scan(&ts, &num); err != nil {
+			return nil, err
+		}
+
+		start := bucketStart(ts, bucket)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, Bucket{Start: start})
+		}
+		buckets[len(buckets)-1].add(num)
+	}
+
+	return buckets, rows.Err()
+}
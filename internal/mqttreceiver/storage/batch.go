@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"time"
+
+	"brutus/internal/mqttreceiver/metrics"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sample — одно значение ряда для пакетной записи
+type Sample struct {
+	Device    string
+	Parameter string
+	Value     string
+	Timestamp time.Time
+	DedupKey  string // пусто — без дедупликации
+
+	// Больше нуля — строка не пишется, если с тем же DedupKey уже есть строка
+	// не раньше чем за DedupWindow до Timestamp
+	DedupWindow time.Duration
+}
+
+// Размер пачки для INSERT: ограничение SQLite на число параметров в одном запросе
+const insertBatchSize = 500
+
+// SaveBatch обновляет текущие значения и добавляет строки истории одной транзакцией.
+// Образцы должны идти в порядке поступления.
+func (db *DB) SaveBatch(samples []Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	unlock := db.recorder.lock(samples)
+	defer unlock()
+
+	type seriesKey struct{ device, parameter string }
+	current := make(map[seriesKey]int, len(samples))
+	currents := make([]CurrentValue, 0, len(samples))
+	history := make([]History, 0, len(samples))
+	dedup := false
+	pending := make(pendingRecords)
+	var oldest time.Time // самая ранняя строка истории в пачке
+
+	for _, smp := range samples {
+		ts := smp.Timestamp.UTC().Truncate(time.Millisecond)
+
+		controlType, err := db.controlType(smp.Device, smp.Parameter)
+		if err != nil {
+			return err
+		}
+		valueType, num := ClassifyValue(smp.Value, controlType)
+
+		// Для текущего значения в пачке достаточно последнего по ряду
+		cv := CurrentValue{
+			Device:    smp.Device,
+			Parameter: smp.Parameter,
+			Value:     smp.Value,
+			ValueType: valueType,
+			NumValue:  num,
+			UpdatedAt: ts,
+		}
+		key := seriesKey{smp.Device, smp.Parameter}
+		if i, ok := current[key]; ok {
+			currents[i] = cv
+		} else {
+			current[key] = len(currents)
+			currents = append(currents, cv)
+		}
+
+		// Ряды с правилом none в историю не пишем
+		if r, ok := db.retention.Lookup(smp.Device, smp.Parameter); ok && r.Skip {
+			continue
+		}
+		if smp.DedupKey != "" && smp.DedupWindow > 0 {
+			dup, err := db.recentlyWritten(smp.DedupKey, ts, smp.DedupWindow, history)
+			if err != nil {
+				return err
+			}
+			if dup {
+				metrics.DuplicateMessages.Inc()
+				continue
+			}
+		}
+		// Текущее значение обновлено всегда, а история — по правилам записи
+		if !db.recorder.shouldRecord(pending, smp.Device, smp.Parameter, smp.Value, num, ts) {
+			continue
+		}
+		h := History{
+			Device:    smp.Device,
+			Parameter: smp.Parameter,
+			Value:     smp.Value,
+			ValueType: valueType,
+			NumValue:  num,
+			Timestamp: ts,
+		}
+		if smp.DedupKey != "" {
+			key := smp.DedupKey
+			h.DedupKey = &key
+			dedup = true
+		}
+		history = append(history, h)
+		if oldest.IsZero() || ts.Before(oldest) {
+			oldest = ts
+		}
+	}
+
+	err := db.Conn.Transaction(func(tx *gorm.DB) error {
+		// Upsert текущих значений; более старое значение не перетирает новое
+		if err := tx.
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "device"}, {Name: "parameter"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "value_type", "num_value", "updated_at"}),
+				Where: clause.Where{Exprs: []clause.Expression{
+					clause.Expr{SQL: "current_values.updated_at <= excluded.updated_at"},
+				}},
+			}).
+			CreateInBatches(currents, insertBatchSize).Error; err != nil {
+			return err
+		}
+
+		if len(history) == 0 {
+			return nil
+		}
+		// Строки старше задержки закрытия корзины могли попасть в уже посчитанные корзины уровней
+		if oldest.Before(time.Now().Add(-rollupGrace)) {
+			if err := db.markRollupsDirty(tx, oldest); err != nil {
+				return err
+			}
+		}
+		if !dedup {
+			return tx.CreateInBatches(history, insertBatchSize).Error
+		}
+
+		// Строку, уже записанную другой репликой, пропускаем
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(history, insertBatchSize)
+		if res.Error != nil {
+			return res.Error
+		}
+		if skipped := int64(len(history)) - res.RowsAffected; skipped > 0 {
+			metrics.DuplicateMessages.Add(float64(skipped))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db.recorder.apply(pending)
+	return nil
+}
+
+// recentlyWritten проверяет, есть ли в пачке или в истории строка с тем же ключом
+// за окно window до ts: так отсекается повторная доставка сообщения без времени публикации
+func (db *DB) recentlyWritten(key string, ts time.Time, window time.Duration, batch []History) (bool, error) {
+	from := ts.Add(-window)
+	for _, h := range batch {
+		if h.DedupKey != nil && *h.DedupKey == key && !h.Timestamp.Before(from) && !h.Timestamp.After(ts) {
+			return true, nil
+		}
+	}
+
+	var ids []uint
+	err := db.Conn.Model(&History{}).
+		Where("dedup_key = ? AND timestamp >= ? AND timestamp <= ?", key, from, ts).
+		Limit(1).
+		Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды SQLite, при которых запись стоит повторить: БД занята другим соединением,
+// ошибка ввода-вывода или нет места на диске
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+	sqliteIOErr  = 10
+	sqliteFull   = 13
+)
+
+// IsTransient сообщает, что ошибка записи временная (нет соединения с БД, БД занята)
+// и запись имеет смысл повторить. Остальные ошибки — например, нарушение ограничения
+// или значение, которое драйвер не принимает, — при повторе не исчезнут.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // ошибки соединения
+			return true
+		case strings.HasPrefix(pgErr.Code, "53"): // нехватка ресурсов: диск, память, соединения
+			return true
+		case strings.HasPrefix(pgErr.Code, "57P"): // сервер останавливается или еще не готов
+			return true
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // конфликт сериализации, взаимоблокировка
+			return true
+		}
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+		return true
+	}
+
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff { // расширенный код содержит основной в младшем байте
+		case sqliteBusy, sqliteLocked, sqliteIOErr, sqliteFull:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqliteError повторяет ошибку драйвера SQLite с кодом результата
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e sqliteError) Code() int     { return int(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad connection", driver.ErrBadConn, true},
+		{"wrapped bad connection", fmt.Errorf("save: %w", driver.ErrBadConn), true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"postgres connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"postgres too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"postgres shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"postgres deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"postgres unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"postgres invalid encoding", &pgconn.PgError{Code: "22021"}, false},
+		{"sqlite busy", sqliteError(sqliteBusy), true},
+		{"sqlite busy recovery", sqliteError(sqliteBusy | 1<<8), true},
+		{"sqlite disk full", sqliteError(sqliteFull), true},
+		{"sqlite constraint", sqliteError(19), false},
+		{"plain error", errors.New("unsupported value"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
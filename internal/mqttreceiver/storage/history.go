@@ -0,0 +1,98 @@
+// internal/mqttreceiver/storage/history.go
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HistoryCursor — позиция строки истории для постраничного чтения
+type HistoryCursor struct {
+	Timestamp time.Time
+	ID        uint
+}
+
+// HistoryQuery — выборка истории одного ряда за период
+type HistoryQuery struct {
+	Device     string
+	Parameter  string
+	StartMs    int64 // Unix timestamp in milliseconds, включительно
+	EndMs      int64 // Unix timestamp in milliseconds, включительно
+	Conds      []ValueCondition
+	Limit      int            // 0 — без ограничения
+	Descending bool           // от новых к старым
+	After      *HistoryCursor // продолжить после этой строки
+}
+
+// historyScope строит запрос с условиями, курсором и порядком строк.
+// Порядок (timestamp, id) однозначен, поэтому страницы не теряют и не повторяют строк.
+func (db *DB) historyScope(q HistoryQuery) *gorm.DB {
+	startTime := time.UnixMilli(q.StartMs).UTC().Truncate(time.Millisecond)
+	endTime := time.UnixMilli(q.EndMs).UTC().Truncate(time.Millisecond)
+
+	tx := db.Conn.Model(&History{}).
+		Where("device = ? AND parameter = ? AND timestamp BETWEEN ? AND ?",
+			q.Device, q.Parameter, startTime, endTime)
+	for _, c := range q.Conds {
+		tx = tx.Where("num_value "+c.Op+" ?", c.Value)
+	}
+
+	order := "timestamp ASC, id ASC"
+	if q.Descending {
+		order = "timestamp DESC, id DESC"
+	}
+	if q.After != nil {
+		op := ">"
+		if q.Descending {
+			op = "<"
+		}
+		ts := q.After.Timestamp.UTC()
+		tx = tx.Where("(timestamp "+op+" ? OR (timestamp = ? AND id "+op+" ?))", ts, ts, q.After.ID)
+	}
+
+	tx = tx.Order(order)
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	return tx
+}
+
+// QueryHistory возвращает одну страницу истории
+func (db *DB) QueryHistory(q HistoryQuery) ([]History, error) {
+	var history []History
+	err := db.historyScope(q).Find(&history).Error
+	return history, err
+}
+
+// StreamHistory читает историю курсором БД и передает строки в fn пачками по chunkSize,
+// не загружая всю выборку в память. Ошибка fn прерывает чтение.
+func (db *DB) StreamHistory(q HistoryQuery, chunkSize int, fn func([]History) error) error {
+	rows, err := db.historyScope(q).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	chunk := make([]History, 0, chunkSize)
+	for rows.Next() {
+		var h History
+		if err := db.Conn.ScanRows(rows, &h); err != nil {
+			return err
+		}
+		chunk = append(chunk, h)
+		if len(chunk) == chunkSize {
+			if err := fn(chunk); err != nil {
+				return err
+			}
+			chunk = make([]History, 0, chunkSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(chunk) > 0 {
+		return fn(chunk)
+	}
+	return nil
+}
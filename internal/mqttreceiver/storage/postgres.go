@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// OpenPostgres подключается к PostgreSQL; с timescale=true история хранится в гипертаблице,
+// а прореживание считается через time_bucket на стороне БД
+func OpenPostgres(dsn string, timescale bool, maxOpenConns int) (*DB, error) {
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.NewGormLogger(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	if maxOpenConns < 1 {
+		maxOpenConns = 1
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	dialect := DialectPostgres
+	if timescale {
+		dialect = DialectTimescale
+	}
+	db, err := newDB(conn, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if timescale {
+		if err := setupHypertable(conn); err != nil {
+			return nil, fmt.Errorf("timescale setup: %w", err)
+		}
+	}
+
+	logger.Log.Info().
+		Str("component", "storage").
+		Str("dialect", dialect).
+		Msg("Database initialized")
+
+	return db, nil
+}
+
+// setupHypertable превращает таблицу истории в гипертаблицу TimescaleDB.
+// Уникальные ключи гипертаблицы обязаны включать колонку времени,
+// поэтому первичный ключ расширяется до (id, timestamp).
+func setupHypertable(conn *gorm.DB) error {
+	if err := conn.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := conn.Raw(`SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables
+		WHERE hypertable_name = 'histories')`).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE histories DROP CONSTRAINT IF EXISTS histories_pkey,
+			ADD PRIMARY KEY (id, timestamp)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`SELECT create_hypertable('histories', 'timestamp',
+			migrate_data => true, if_not_exists => true)`).Error
+	})
+}
+
+// aggregateTimescale считает корзины в БД: time_bucket с началом отсчета от Unix epoch,
+// чтобы границы совпадали с остальными диалектами
+func (db *DB) aggregateTimescale(device, parameter string, startTime, endTime time.Time, bucket time.Duration) ([]Bucket, error) {
+	var rows []struct {
+		Start    time.Time
+		Count    int64
+		NumCount int64
+		Min      *float64
+		Max      *float64
+		Sum      *float64
+		First    *float64
+		Last     *float64
+	}
+	err := db.Conn.Raw(`
+		SELECT time_bucket(make_interval(secs => ?), timestamp, TIMESTAMPTZ '1970-01-01 00:00:00+00') AS start,
+			count(*) AS count,
+			count(num_value) AS num_count,
+			min(num_value) AS min,
+			max(num_value) AS max,
+			sum(num_value) AS sum,
+			first(num_value, timestamp) FILTER (WHERE num_value IS NOT NULL) AS first,
+			last(num_value, timestamp) FILTER (WHERE num_value IS NOT NULL) AS last
+		FROM histories
+		WHERE device = ? AND parameter = ? AND timestamp BETWEEN ? AND ?
+		GROUP BY start
+		ORDER BY start ASC`,
+		bucket.Seconds(), device, parameter, startTime, endTime).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]Bucket, 0, len(rows))
+	for _, r := range rows {
+		b := Bucket{Start: r.Start.UTC(), Count: r.Count, NumCount: r.NumCount}
+		if r.NumCount > 0 {
+			b.Min, b.Max, b.Sum = deref(r.Min), deref(r.Max), deref(r.Sum)
+			b.First, b.Last = deref(r.First), deref(r.Last)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+func deref(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
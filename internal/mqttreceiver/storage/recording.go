@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/policy"
+)
+
+// recorded — последнее значение ряда, записанное в историю
+type recorded struct {
+	value string
+	num   *float64
+	at    time.Time
+}
+
+// Число полос блокировок рядов в recorder
+const recorderStripes = 64
+
+// recorder решает по правилам записи, нужна ли новая строка истории.
+// Состояние живет в памяти: после перезапуска первое значение каждого ряда пишется всегда.
+type recorder struct {
+	mu    sync.Mutex
+	rules policy.RecordingRules
+	last  map[string]recorded
+
+	// Ряды с правилами записи заняты пачкой от решения до apply: иначе два воркера
+	// сравнили бы соседние значения ряда с одним и тем же прежним и оба записали
+	// или оба пропустили. Ряды делят полосы по хешу.
+	stripes [recorderStripes]sync.Mutex
+}
+
+func newRecorder() *recorder {
+	return &recorder{last: make(map[string]recorded)}
+}
+
+func (r *recorder) setRules(rules policy.RecordingRules) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = rules
+	r.last = make(map[string]recorded)
+}
+
+// lock занимает ряды пачки, у которых есть правила записи, и возвращает функцию освобождения.
+// Полосы берутся по возрастанию номера, поэтому пачки не ждут друг друга по кругу.
+func (r *recorder) lock(samples []Sample) func() {
+	var used [recorderStripes]bool
+	r.mu.Lock()
+	for _, smp := range samples {
+		if _, ok := r.rules.Lookup(smp.Device, smp.Parameter); ok {
+			h := fnv.New32a()
+			h.Write([]byte(smp.Device + "/" + smp.Parameter))
+			used[h.Sum32()%recorderStripes] = true
+		}
+	}
+	r.mu.Unlock()
+
+	var locked []*sync.Mutex
+	for i := range used {
+		if used[i] {
+			r.stripes[i].Lock()
+			locked = append(locked, &r.stripes[i])
+		}
+	}
+	return func() {
+		for _, m := range locked {
+			m.Unlock()
+		}
+	}
+}
+
+// pendingRecords — значения пачки, решенные к записи, но еще не записанные
+type pendingRecords map[string]recorded
+
+// shouldRecord проверяет значение; записываемое значение запоминается в pending
+// и попадает в состояние recorder только через apply, после записи пачки
+func (r *recorder) shouldRecord(pending pendingRecords, device, parameter, value string, num *float64, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule, ok := r.rules.Lookup(device, parameter)
+	if !ok {
+		return true
+	}
+
+	key := device + "/" + parameter
+	prev, seen := pending[key]
+	if !seen {
+		prev, seen = r.last[key]
+	}
+	if seen && !decide(rule, prev, value, num, now) {
+		return false
+	}
+	pending[key] = recorded{value: value, num: num, at: now}
+	return true
+}
+
+// apply запоминает значения записанной пачки; при откате транзакции не вызывается,
+// чтобы незаписанные значения не подавляли следующие
+func (r *recorder) apply(pending pendingRecords) {
+	if len(pending) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, rec := range pending {
+		r.last[key] = rec
+	}
+}
+
+func decide(rule policy.Recording, prev recorded, value string, num *float64, now time.Time) bool {
+	elapsed := now.Sub(prev.at)
+	if rule.Heartbeat > 0 && elapsed >= rule.Heartbeat {
+		return true
+	}
+	if rule.MinInterval > 0 && elapsed < rule.MinInterval {
+		return false
+	}
+
+	// Зона нечувствительности считается от последнего записанного значения
+	if (rule.Deadband > 0 || rule.DeadbandPct > 0) && num != nil && prev.num != nil {
+		diff := math.Abs(*num - *prev.num)
+		if rule.Deadband > 0 && diff >= rule.Deadband {
+			return true
+		}
+		if rule.DeadbandPct > 0 && diff > 0 && diff >= math.Abs(*prev.num)*rule.DeadbandPct/100 {
+			return true
+		}
+		return false
+	}
+
+	if rule.OnChange || rule.Deadband > 0 || rule.DeadbandPct > 0 {
+		return value != prev.value
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+// internal/mqttreceiver/storage/recording_test.go
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"brutus/internal/mqttreceiver/policy"
+)
+
+func TestDecide(t *testing.T) {
+	num := func(f float64) *float64 { return &f }
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	prev := recorded{value: "10", num: num(10), at: at}
+	tests := []struct {
+		name    string
+		rule    policy.Recording
+		value   string
+		num     *float64
+		elapsed time.Duration
+		want    bool
+	}{
+		{"no options", policy.Recording{}, "10", num(10), time.Second, true},
+		{"on_change same", policy.Recording{OnChange: true}, "10", num(10), time.Second, false},
+		{"on_change changed", policy.Recording{OnChange: true}, "11", num(11), time.Second, true},
+		{"on_change text", policy.Recording{OnChange: true}, "on", nil, time.Second, true},
+		{"deadband inside", policy.Recording{Deadband: 0.5}, "10.4", num(10.4), time.Second, false},
+		{"deadband boundary", policy.Recording{Deadband: 0.5}, "9.5", num(9.5), time.Second, true},
+		{"deadband text falls back to change", policy.Recording{Deadband: 0.5}, "10", nil, time.Second, false},
+		{"deadband_pct inside", policy.Recording{DeadbandPct: 5}, "10.4", num(10.4), time.Second, false},
+		{"deadband_pct outside", policy.Recording{DeadbandPct: 5}, "10.5", num(10.5), time.Second, true},
+		{"deadband_pct same value", policy.Recording{DeadbandPct: 5}, "10", num(10), time.Second, false},
+		{"either deadband", policy.Recording{Deadband: 5, DeadbandPct: 1}, "10.2", num(10.2), time.Second, true},
+		{"min_interval too soon", policy.Recording{MinInterval: time.Minute}, "20", num(20), time.Second, false},
+		{"min_interval passed", policy.Recording{MinInterval: time.Minute}, "20", num(20), time.Minute, true},
+		{"heartbeat without change", policy.Recording{OnChange: true, Heartbeat: time.Minute}, "10", num(10), time.Minute, true},
+		{"heartbeat beats min_interval", policy.Recording{MinInterval: time.Hour, Heartbeat: time.Minute}, "10", num(10), 2 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := decide(tt.rule, prev, tt.value, tt.num, at.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("%s: decide = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Пачка другого воркера с тем же рядом ждет, пока первая не запишется, и сравнивает
+// значение уже с записанным первой, а не с тем же прежним
+func TestRecorderLockSeries(t *testing.T) {
+	rules, err := policy.ParseRecording("wb-adc/*=on_change")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRecorder()
+	r.setRules(rules)
+	now := time.Now()
+	batch := []Sample{{Device: "wb-adc", Parameter: "A1", Value: "1", Timestamp: now}}
+
+	unlockFirst := r.lock(batch)
+	first := make(pendingRecords)
+	if !r.shouldRecord(first, "wb-adc", "A1", "1", nil, now) {
+		t.Fatal("first value of a series not recorded")
+	}
+
+	result := make(chan bool)
+	go func() {
+		unlock := r.lock(batch)
+		defer unlock()
+		result <- r.shouldRecord(make(pendingRecords), "wb-adc", "A1", "1", nil, now.Add(time.Second))
+	}()
+	select {
+	case <-result:
+		t.Fatal("second batch decided while the first one was not written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.apply(first)
+	unlockFirst()
+	if <-result {
+		t.Error("unchanged value recorded twice")
+	}
+
+	// Ряды без правил записи не блокируются
+	free := []Sample{{Device: "wb-gpio", Parameter: "A1", Value: "1", Timestamp: now}}
+	unlockFirst = r.lock(batch)
+	done := make(chan struct{})
+	go func() {
+		r.lock(free)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("series without a recording rule waited for another batch")
+	}
+	unlockFirst()
+}
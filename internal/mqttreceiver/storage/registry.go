@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Структура устройства из meta-топиков /devices/<d>/meta/*
+type Device struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex"`
+	Title     string
+	Driver    string
+	Error     string
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false"`
+	Controls  []Control `gorm:"-"`
+}
+
+// Структура контрола из meta-топиков /devices/<d>/controls/<c>/meta/*
+type Control struct {
+	ID        uint   `gorm:"primaryKey"`
+	Device    string `gorm:"uniqueIndex:idx_controls_device_name"`
+	Name      string `gorm:"uniqueIndex:idx_controls_device_name"`
+	Title     string
+	Type      string
+	Units     string
+	Readonly  bool
+	Order     int `gorm:"column:sort_order"`
+	Min       *float64
+	Max       *float64
+	Error     string
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false"`
+}
+
+// SaveMeta сохраняет одно значение meta устройства (control пустой) или контрола
+func (db *DB) SaveMeta(device, control, key, value string) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		var dev Device
+		if err := tx.
+			Where(Device{Name: device}).
+			Attrs(Device{UpdatedAt: now}).
+			FirstOrCreate(&dev).Error; err != nil {
+			return err
+		}
+
+		if control == "" {
+			switch key {
+			case "name", "title":
+				dev.Title = metaTitle(value)
+			case "driver":
+				dev.Driver = value
+			case "error":
+				dev.Error = value
+			default:
+				return nil
+			}
+			dev.UpdatedAt = now
+			return tx.Save(&dev).Error
+		}
+
+		var ctrl Control
+		if err := tx.
+			Where(Control{Device: device, Name: control}).
+			Attrs(Control{UpdatedAt: now}).
+			FirstOrCreate(&ctrl).Error; err != nil {
+			return err
+		}
+
+		switch key {
+		case "name", "title":
+			ctrl.Title = metaTitle(value)
+		case "type":
+			ctrl.Type = value
+			db.controlTypes.Store(device+"/"+control, value)
+		case "units":
+			ctrl.Units = value
+		case "readonly":
+			ctrl.Readonly = value == "1" || value == "true"
+		case "order":
+			ctrl.Order, _ = strconv.Atoi(value)
+		case "min":
+			ctrl.Min = metaFloat(value)
+		case "max":
+			ctrl.Max = metaFloat(value)
+		case "error":
+			ctrl.Error = value
+		default:
+			return nil
+		}
+		ctrl.UpdatedAt = now
+		return tx.Save(&ctrl).Error
+	})
+}
+
+// GetDevices возвращает устройства вместе с их контролами.
+// Пустой device — все устройства.
+func (db *DB) GetDevices(device string) ([]Device, error) {
+	q := db.Conn.Order("name ASC")
+	if device != "" {
+		q = q.Where("name = ?", device)
+	}
+
+	var devices []Device
+	if err := q.Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return devices, nil
+	}
+
+	names := make([]string, 0, len(devices))
+	index := make(map[string]int, len(devices))
+	for i, d := range devices {
+		names = append(names, d.Name)
+		index[d.Name] = i
+	}
+
+	var controls []Control
+	if err := db.Conn.
+		Where("device IN ?", names).
+		Order("sort_order ASC, name ASC").
+		Find(&controls).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range controls {
+		i := index[c.Device]
+		devices[i].Controls = append(devices[i].Controls, c)
+	}
+
+	return devices, nil
+}
+
+// GetControl возвращает meta контрола или nil, если она еще не приходила
+func (db *DB) GetControl(device, control string) (*Control, error) {
+	// Find вместо First, чтобы отсутствие meta не логировалось как ошибка
+	var ctrl Control
+	result := db.Conn.Where("device = ? AND name = ?", device, control).Limit(1).Find(&ctrl)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &ctrl, nil
+}
+
+// controlType возвращает тип контрола из кэша, при промахе читает meta из БД
+func (db *DB) controlType(device, control string) (string, error) {
+	key := device + "/" + control
+	if t, ok := db.controlTypes.Load(key); ok {
+		return t.(string), nil
+	}
+
+	ctrl, err := db.GetControl(device, control)
+	if err != nil {
+		return "", err
+	}
+	t := ""
+	if ctrl != nil {
+		t = ctrl.Type
+	}
+	db.controlTypes.Store(key, t)
+	return t, nil
+}
+
+// metaTitle принимает как строку, так и JSON вида {"en": "...", "ru": "..."}
+func metaTitle(value string) string {
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return value
+	}
+
+	var titles map[string]string
+	if err := json.Unmarshal([]byte(value), &titles); err != nil {
+		return value
+	}
+	for _, lang := range []string{"en", "ru"} {
+		if t, ok := titles[lang]; ok {
+			return t
+		}
+	}
+	for _, t := range titles {
+		return t
+	}
+	return ""
+}
+
+func metaFloat(value string) *float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rollup — строка агрегированной таблицы истории (history_1m, history_1h, history_1d)
+type Rollup struct {
+	ID        uint `gorm:"primaryKey"`
+	Device    string
+	Parameter string
+	Bucket    time.Time
+	Count     int64
+	NumCount  int64
+	Min       float64
+	Max       float64
+	Sum       float64
+	First     float64
+	Last      float64
+}
+
+// RollupState хранит, до какого момента уровень уже посчитан, и с какого момента его нужно
+// пересчитать из-за строк, записанных задним числом (повтор очереди, время публикации MQTT 5)
+type RollupState struct {
+	Tier      string `gorm:"primaryKey"`
+	Watermark time.Time
+	DirtyFrom *time.Time
+	// Растет при каждой отметке: отметку, сделанную во время пересчета, пересчет не снимает
+	DirtySeq int64 `gorm:"not null;default:0"`
+}
+
+// RollupTier — уровень агрегации со своим сроком хранения (0 — хранить всегда)
+type RollupTier struct {
+	Name          string
+	Table         string
+	Resolution    time.Duration
+	Chunk         time.Duration // сколько данных источника обрабатывать за одну транзакцию
+	RetentionDays int
+}
+
+// Уровни идут от мелкого к крупному: каждый строится из предыдущего, первый — из сырой истории
+var defaultRollupTiers = []RollupTier{
+	{Name: "1m", Table: "history_1m", Resolution: time.Minute, Chunk: time.Hour, RetentionDays: 30},
+	{Name: "1h", Table: "history_1h", Resolution: time.Hour, Chunk: 24 * time.Hour, RetentionDays: 730},
+	{Name: "1d", Table: "history_1d", Resolution: 24 * time.Hour, Chunk: 30 * 24 * time.Hour},
+}
+
+// Задержка перед закрытием корзины, чтобы успели записаться запоздавшие сообщения
+const rollupGrace = 30 * time.Second
+
+func migrateRollups(db *gorm.DB, tiers []RollupTier) error {
+	if err := db.AutoMigrate(&RollupState{}); err != nil {
+		return err
+	}
+	for _, tier := range tiers {
+		if err := db.Table(tier.Table).AutoMigrate(&Rollup{}); err != nil {
+			return err
+		}
+		// Индекс создаем вручную: имя в теге структуры было бы общим для всех таблиц
+		if err := db.Exec(fmt.Sprintf(
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_series ON %s (device, parameter, bucket)",
+			tier.Table, tier.Table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRollupRetention задает сроки хранения уровней в днях по имени уровня (1m, 1h, 1d)
+func (db *DB) SetRollupRetention(days map[string]int) {
+	for i := range db.rollupTiers {
+		if d, ok := days[db.rollupTiers[i].Name]; ok {
+			db.rollupTiers[i].RetentionDays = d
+		}
+	}
+}
+
+// SetHistoryRetention задает срок хранения сырой истории в днях: уровень 1m не пересчитывается
+// за период, где сырая история уже удалена
+func (db *DB) SetHistoryRetention(days int) {
+	db.historyRetentionDays = days
+}
+
+// RunRollups досчитывает все уровни до последней закрытой корзины
+func (db *DB) RunRollups(now time.Time) error {
+	for i, tier := range db.rollupTiers {
+		var source *RollupTier
+		if i > 0 {
+			source = &db.rollupTiers[i-1]
+		}
+		if err := db.runRollupTier(tier, source, now); err != nil {
+			return fmt.Errorf("rollup %s: %w", tier.Name, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) runRollupTier(tier RollupTier, source *RollupTier, now time.Time) error {
+	end := bucketStart(now.Add(-rollupGrace), tier.Resolution)
+	if source != nil {
+		// Крупный уровень не может опережать источник
+		srcMark, err := db.rollupWatermark(source.Name)
+		if err != nil {
+			return err
+		}
+		end = bucketStart(srcMark, tier.Resolution)
+	}
+
+	state, err := db.rollupState(tier.Name)
+	if err != nil {
+		return err
+	}
+	from := state.Watermark
+	if from.IsZero() {
+		// Первый запуск: начинаем с самых старых данных источника
+		if from, err = db.oldestSource(source); err != nil || from.IsZero() {
+			return err
+		}
+		from = bucketStart(from, tier.Resolution)
+	}
+	if state.DirtyFrom != nil {
+		if dirty := db.rerollFrom(tier, source, *state.DirtyFrom, now); dirty.Before(from) {
+			logger.Log.Info().
+				Str("component", "storage").
+				Str("tier", tier.Name).
+				Time("from", dirty).
+				Msg("Rebuilding rollups after late history")
+			from = dirty
+		}
+	}
+
+	for from.Before(end) {
+		to := from.Add(tier.Chunk)
+		if to.After(end) {
+			to = end
+		}
+		if err := db.rollupChunk(tier, source, from, to); err != nil {
+			return err
+		}
+		from = to
+	}
+
+	if state.DirtyFrom == nil {
+		return nil
+	}
+	// Отметки, сделанные после чтения состояния, остаются до следующего запуска
+	return db.Conn.Model(&RollupState{}).
+		Where("tier = ? AND dirty_seq = ?", tier.Name, state.DirtySeq).
+		Update("dirty_from", nil).Error
+}
+
+// rerollFrom возвращает начало пересчета уровня после строк, записанных задним числом с dirty.
+// Период, где источник уже удален по сроку хранения, не пересчитывается: из неполного
+// источника получились бы неполные корзины.
+func (db *DB) rerollFrom(tier RollupTier, source *RollupTier, dirty, now time.Time) time.Time {
+	from := bucketStart(dirty, tier.Resolution)
+	days := db.historyRetentionDays
+	if source != nil {
+		days = source.RetentionDays
+	}
+	if days > 0 {
+		// Корзина, на которую пришлась граница удаления, могла остаться неполной
+		if limit := bucketStart(now.AddDate(0, 0, -days), tier.Resolution).Add(tier.Resolution); from.Before(limit) {
+			from = limit
+		}
+	}
+	return from
+}
+
+// markRollupsDirty отмечает, что уровни нужно пересчитать начиная с ts; вызывается в транзакции
+// записи истории, когда строки пишутся задним числом
+func (db *DB) markRollupsDirty(tx *gorm.DB, ts time.Time) error {
+	for _, tier := range db.rollupTiers {
+		start := bucketStart(ts, tier.Resolution)
+		if err := tx.Model(&RollupState{}).
+			Where("tier = ?", tier.Name).
+			Updates(map[string]any{
+				"dirty_from": gorm.Expr("CASE WHEN dirty_from IS NULL OR dirty_from > ? THEN ? ELSE dirty_from END", start, start),
+				"dirty_seq":  gorm.Expr("dirty_seq + 1"),
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupChunk агрегирует источник за [from, to) и пишет результат вместе с watermark
+func (db *DB) rollupChunk(tier RollupTier, source *RollupTier, from, to time.Time) error {
+	type seriesKey struct{ device, parameter string }
+	buckets := make(map[seriesKey]map[time.Time]*Bucket)
+	bucketFor := func(device, parameter string, ts time.Time) *Bucket {
+		key := seriesKey{device, parameter}
+		series, ok := buckets[key]
+		if !ok {
+			series = make(map[time.Time]*Bucket)
+			buckets[key] = series
+		}
+		start := bucketStart(ts, tier.Resolution)
+		b, ok := series[start]
+		if !ok {
+			b = &Bucket{Start: start}
+			series[start] = b
+		}
+		return b
+	}
+
+	// Источник читаем целиком до записи: при пуле из одного соединения
+	// открытый курсор заблокировал бы транзакцию
+	if source == nil {
+		var rows []History
+		if err := db.Conn.
+			Select("device, parameter, num_value, timestamp").
+			Where("timestamp >= ? AND timestamp < ?", from, to).
+			Order("timestamp ASC").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, h := range rows {
+			bucketFor(h.Device, h.Parameter, h.Timestamp).add(h.NumValue)
+		}
+	} else {
+		var rows []Rollup
+		if err := db.Conn.Table(source.Table).
+			Where("bucket >= ? AND bucket < ?", from, to).
+			Order("bucket ASC").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			bucketFor(r.Device, r.Parameter, r.Bucket).merge(r.bucket())
+		}
+	}
+
+	var out []Rollup
+	for key, series := range buckets {
+		for _, b := range series {
+			out = append(out, rollupFromBucket(key.device, key.parameter, b))
+		}
+	}
+
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		if len(out) > 0 {
+			if err := tx.Table(tier.Table).
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "device"}, {Name: "parameter"}, {Name: "bucket"}},
+					DoUpdates: clause.AssignmentColumns([]string{"count", "num_count", "min", "max", "sum", "first", "last"}),
+				}).
+				CreateInBatches(out, 500).Error; err != nil {
+				return err
+			}
+		}
+		// Отметку пересчета не трогаем: ее снимает runRollupTier
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tier"}},
+			DoUpdates: clause.AssignmentColumns([]string{"watermark"}),
+		}).Create(&RollupState{Tier: tier.Name, Watermark: to}).Error
+	})
+}
+
+func (db *DB) rollupWatermark(tier string) (time.Time, error) {
+	state, err := db.rollupState(tier)
+	return state.Watermark, err
+}
+
+func (db *DB) rollupState(tier string) (RollupState, error) {
+	var state RollupState
+	if err := db.Conn.Where("tier = ?", tier).Limit(1).Find(&state).Error; err != nil {
+		return RollupState{}, err
+	}
+	state.Watermark = state.Watermark.UTC()
+	if state.DirtyFrom != nil {
+		dirty := state.DirtyFrom.UTC()
+		state.DirtyFrom = &dirty
+	}
+	return state, nil
+}
+
+func (db *DB) oldestSource(source *RollupTier) (time.Time, error) {
+	var row struct{ Ts time.Time }
+	q := db.Conn.Model(&History{}).Select("timestamp AS ts").Order("timestamp ASC")
+	if source != nil {
+		q = db.Conn.Table(source.Table).Select("bucket AS ts").Order("bucket ASC")
+	}
+	result := q.Limit(1).Scan(&row)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return time.Time{}, result.Error
+	}
+	return row.Ts.UTC(), nil
+}
+
+// CleanOldRollups удаляет строки уровней старше их срока хранения
+func (db *DB) CleanOldRollups() error {
+	for _, tier := range db.rollupTiers {
+		if tier.RetentionDays <= 0 {
+			continue
+		}
+		cutoff := time.Now().UTC().AddDate(0, 0, -tier.RetentionDays)
+		result := db.Conn.Table(tier.Table).Where("bucket < ?", cutoff).Delete(&Rollup{})
+		if result.Error != nil {
+			return result.Error
+		}
+		logger.Log.Debug().
+			Str("component", "storage").
+			Str("tier", tier.Name).
+			Int64("rows", result.RowsAffected).
+			Msg("Old rollups cleaned")
+	}
+	return nil
+}
+
+// rollupTierFor выбирает самый крупный уровень, из которого собирается корзина размера bucket
+// и который по сроку хранения еще содержит данные с from
+func (db *DB) rollupTierFor(bucket time.Duration, from, now time.Time) *RollupTier {
+	for i := len(db.rollupTiers) - 1; i >= 0; i-- {
+		tier := &db.rollupTiers[i]
+		if bucket < tier.Resolution || bucket%tier.Resolution != 0 {
+			continue
+		}
+		if tier.RetentionDays > 0 && from.Before(now.AddDate(0, 0, -tier.RetentionDays)) {
+			continue
+		}
+		return tier
+	}
+	return nil
+}
+
+// aggregateRollups собирает корзины из уровня с from (выровнен по корзине уровня) и возвращает,
+// докуда взяты данные уровня; остаток периода до to берется из сырой истории
+func (db *DB) aggregateRollups(tier *RollupTier, device, parameter string, from, to time.Time, bucket time.Duration) ([]Bucket, time.Time, error) {
+	mark, err := db.rollupWatermark(tier.Name)
+	if err != nil || mark.IsZero() {
+		return nil, time.Time{}, err
+	}
+	// Корзина уровня, в которую попадает to, содержит и строки позже to — ее берем из сырой истории
+	if end := bucketStart(to, tier.Resolution); mark.After(end) {
+		mark = end
+	}
+
+	var rows []Rollup
+	if err := db.Conn.Table(tier.Table).
+		Where("device = ? AND parameter = ? AND bucket >= ? AND bucket < ?",
+			device, parameter, bucketStart(from, tier.Resolution), mark).
+		Order("bucket ASC").
+		Find(&rows).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var buckets []Bucket
+	for _, r := range rows {
+		start := bucketStart(r.Bucket, bucket)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, Bucket{Start: start})
+		}
+		buckets[len(buckets)-1].merge(r.bucket())
+	}
+	return buckets, mark, nil
+}
+
+func (r *Rollup) bucket() Bucket {
+	return Bucket{
+		Start:    r.Bucket.UTC(),
+		Count:    r.Count,
+		NumCount: r.NumCount,
+		Min:      r.Min,
+		Max:      r.Max,
+		Sum:      r.Sum,
+		First:    r.First,
+		Last:     r.Last,
+	}
+}
+
+func rollupFromBucket(device, parameter string, b *Bucket) Rollup {
+	return Rollup{
+		Device:    device,
+		Parameter: parameter,
+		Bucket:    b.Start,
+		Count:     b.Count,
+		NumCount:  b.NumCount,
+		Min:       b.Min,
+		Max:       b.Max,
+		Sum:       b.Sum,
+		First:     b.First,
+		Last:      b.Last,
+	}
+}
@@ -0,0 +1,197 @@
+// internal/mqttreceiver/storage/rollup_test.go
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Initialized(filepath.Join(t.TempDir(), "test.db"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func saveValues(t *testing.T, db *DB, values map[time.Time]float64) {
+	t.Helper()
+	var samples []Sample
+	for ts, v := range values {
+		samples = append(samples, Sample{
+			Device:    "wb-adc",
+			Parameter: "A1",
+			Value:     strconv.FormatFloat(v, 'g', -1, 64),
+			Timestamp: ts,
+		})
+	}
+	if err := db.SaveBatch(samples); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		size time.Duration
+		want time.Time
+	}{
+		{base, time.Minute, base},
+		{base.Add(59 * time.Second), time.Minute, base},
+		{base.Add(time.Minute), time.Minute, base.Add(time.Minute)},
+		{base.Add(90 * time.Minute), time.Hour, base.Add(time.Hour)},
+		{base.Add(13 * time.Hour), 24 * time.Hour, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{base.Add(7 * time.Minute), 5 * time.Minute, base.Add(5 * time.Minute)},
+		{time.UnixMilli(-1), time.Second, time.UnixMilli(-1000).UTC()},
+		{base.In(time.FixedZone("MSK", 3*3600)).Add(30 * time.Second), time.Minute, base},
+	}
+	for _, tt := range tests {
+		if got := bucketStart(tt.t, tt.size); !got.Equal(tt.want) {
+			t.Errorf("bucketStart(%v, %v) = %v, want %v", tt.t, tt.size, got, tt.want)
+		}
+	}
+}
+
+// Корзины из уровня rollup и из сырой истории на тот же запрос совпадают,
+// в том числе когда начало и конец периода попадают внутрь корзины уровня
+func TestAggregatedHistoryRollupMatchesRaw(t *testing.T) {
+	db := openTestDB(t)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	saveValues(t, db, map[time.Time]float64{
+		base.Add(-30 * time.Second): 100,
+		base.Add(10 * time.Second):  1,
+		base.Add(50 * time.Second):  3,
+		base.Add(80 * time.Second):  5,
+		base.Add(100 * time.Second): 7,
+	})
+
+	ranges := []struct{ start, end time.Time }{
+		{base.Add(30 * time.Second), base.Add(90 * time.Second)},
+		{base.Add(-10 * time.Second), base.Add(30 * time.Second)},
+		{base, base.Add(2 * time.Minute)},
+	}
+	raw := make([][]Bucket, len(ranges))
+	for i, r := range ranges {
+		b, err := db.GetAggregatedHistory("wb-adc", "A1", r.start.UnixMilli(), r.end.UnixMilli(), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw[i] = b
+	}
+
+	if err := db.RunRollups(base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range ranges {
+		rolled, err := db.GetAggregatedHistory("wb-adc", "A1", r.start.UnixMilli(), r.end.UnixMilli(), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rolled, raw[i]) {
+			t.Errorf("range %d: rollup buckets %+v, raw buckets %+v", i, rolled, raw[i])
+		}
+	}
+
+	// Начало периода выравнивается по корзине: первая корзина содержит оба значения минуты
+	if got := raw[0][0]; !got.Start.Equal(base) || got.Count != 2 || got.Sum != 4 {
+		t.Errorf("first bucket = %+v, want start %v with values 1 and 3", got, base)
+	}
+}
+
+func TestRollupTierFor(t *testing.T) {
+	db := &DB{rollupTiers: append([]RollupTier(nil), defaultRollupTiers...)}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		bucket time.Duration
+		from   time.Time
+		want   string // пусто — сырая история
+	}{
+		{30 * time.Second, now.Add(-time.Hour), ""},
+		{90 * time.Second, now.Add(-time.Hour), ""},
+		{time.Minute, now.Add(-time.Hour), "1m"},
+		{5 * time.Minute, now.Add(-time.Hour), "1m"},
+		{2 * time.Hour, now.Add(-time.Hour), "1h"},
+		{day, now.Add(-time.Hour), "1d"},
+		{time.Minute, now.Add(-40 * day), ""},
+		{5 * time.Minute, now.Add(-29 * day), "1m"},
+		{time.Hour, now.Add(-40 * day), "1h"},
+		{time.Hour, now.Add(-800 * day), ""},
+		{7 * day, now.Add(-800 * day), "1d"},
+	}
+	for _, tt := range tests {
+		var got string
+		if tier := db.rollupTierFor(tt.bucket, tt.from, now); tier != nil {
+			got = tier.Name
+		}
+		if got != tt.want {
+			t.Errorf("rollupTierFor(%v, now-%v) = %q, want %q", tt.bucket, now.Sub(tt.from), got, tt.want)
+		}
+	}
+}
+
+// rollupRows возвращает корзины ряда wb-adc/A1 уровня по времени начала
+func rollupRows(t *testing.T, db *DB, table string) map[time.Time]Rollup {
+	t.Helper()
+	var rows []Rollup
+	if err := db.Conn.Table(table).Where("device = ? AND parameter = ?", "wb-adc", "A1").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[time.Time]Rollup, len(rows))
+	for _, r := range rows {
+		out[r.Bucket.UTC()] = r
+	}
+	return out
+}
+
+// Строки, записанные задним числом в уже посчитанные корзины, пересчитываются на всех уровнях
+func TestRollupsRebuildLateRows(t *testing.T) {
+	db := openTestDB(t)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := base.Add(2 * time.Hour)
+
+	saveValues(t, db, map[time.Time]float64{base.Add(10 * time.Second): 1, base.Add(70 * time.Second): 2})
+	if err := db.RunRollups(now); err != nil {
+		t.Fatal(err)
+	}
+	if got := rollupRows(t, db, "history_1h")[base]; got.Count != 2 {
+		t.Fatalf("1h bucket before late rows: count %d, want 2", got.Count)
+	}
+
+	// Повтор очереди после простоя: строки в закрытых корзинах обоих уровней
+	saveValues(t, db, map[time.Time]float64{base.Add(20 * time.Second): 3, base.Add(30 * time.Minute): 4})
+	if err := db.RunRollups(now); err != nil {
+		t.Fatal(err)
+	}
+
+	minutes := rollupRows(t, db, "history_1m")
+	if got := minutes[base]; got.Count != 2 || got.Sum != 4 {
+		t.Errorf("1m bucket %v = %+v, want count 2, sum 4", base, got)
+	}
+	if got := minutes[base.Add(30*time.Minute)]; got.Count != 1 || got.Sum != 4 {
+		t.Errorf("1m bucket %v = %+v, want count 1, sum 4", base.Add(30*time.Minute), got)
+	}
+	if got := rollupRows(t, db, "history_1h")[base]; got.Count != 4 || got.Sum != 10 || got.Last != 4 {
+		t.Errorf("1h bucket %v = %+v, want count 4, sum 10, last 4", base, got)
+	}
+
+	// Отметка пересчета снята, watermark вернулся к прежнему
+	for _, tier := range db.rollupTiers[:2] {
+		state, err := db.rollupState(tier.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.DirtyFrom != nil {
+			t.Errorf("tier %s still marked dirty from %v", tier.Name, *state.DirtyFrom)
+		}
+		if want := bucketStart(now.Add(-rollupGrace), tier.Resolution); tier.Name == "1m" && !state.Watermark.Equal(want) {
+			t.Errorf("tier 1m watermark = %v, want %v", state.Watermark, want)
+		}
+	}
+}
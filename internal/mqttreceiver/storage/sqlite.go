@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"brutus/internal/mqttreceiver/logger"
+	"net/url"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// Настройки SQLite передаются в DSN, чтобы применяться к каждому соединению пула,
+// а не только к первому. _txlock=immediate берет блокировку записи в начале транзакции,
+// тогда конкурирующие писатели ждут по busy_timeout, а не получают SQLITE_BUSY.
+var sqlitePragmas = []string{
+	"journal_mode(WAL)",
+	"synchronous(NORMAL)",
+	"busy_timeout(5000)",
+	"journal_size_limit(1000000)",
+	"cache_size(-10000)", // 10MB cache
+	"foreign_keys(ON)",
+}
+
+// Инициализация БД с режимом WAL и запуском миграции
+func Initialized(dbFile string, maxOpenConns int) (*DB, error) {
+	query := url.Values{"_txlock": {"immediate"}}
+	for _, pragma := range sqlitePragmas {
+		query.Add("_pragma", pragma)
+	}
+	dsn := "file:" + dbFile + "?" + query.Encode()
+
+	// Подключаемся к БД с помощью API GORM
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.NewGormLogger(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Открываем БД для ее последующего конфигурирования
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Настройка подключений:
+	// в WAL читатели не мешают писателю, поэтому пул больше одного соединения
+	// позволяет gRPC-запросам читать, пока воркеры пишут пачки.
+	// Время жизни одного соединения(освобождение ресурсов)
+	if maxOpenConns < 1 {
+		maxOpenConns = 1
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	store, err := newDB(db, DialectSQLite)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info().
+		Str("component", "storage").
+		Str("db_file", dbFile).
+		Msg("Database initialized with WAL mode")
+
+	return store, nil
+}
@@ -1,144 +1,220 @@
 package storage
 
 import (
-	"brutus/internal/mqttreceiver/logger"
+	"brutus/internal/mqttreceiver/policy"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 )
 
 // Структура текущих значений параметров устройств
 type CurrentValue struct {
 	ID        uint   `gorm:"primaryKey"`
-	Device    string `gorm:"index"`
-	Parameter string `gorm:"index"`
+	Device    string `gorm:"index;uniqueIndex:idx_current_values_series"`
+	Parameter string `gorm:"index;uniqueIndex:idx_current_values_series"`
 	Value     string
+	ValueType string
+	NumValue  *float64
 	UpdatedAt time.Time `gorm:"autoUpdateTime:false"`
 }
 
 // Структура истории значений параметров устройств
 type History struct {
 	ID        uint   `gorm:"primaryKey"`
-	Device    string `gorm:"index"`
-	Parameter string `gorm:"index"`
+	Device    string `gorm:"index;index:idx_histories_series,priority:1"`
+	Parameter string `gorm:"index;index:idx_histories_series,priority:2"`
 	Value     string
-	Timestamp time.Time
+	ValueType string
+	NumValue  *float64
+	Timestamp time.Time `gorm:"index;index:idx_histories_series,priority:3;uniqueIndex:idx_histories_dedup,priority:2"`
+	// Ключ идемпотентной записи в кластерном режиме; NULL — без дедупликации.
+	// Индекс включает время: уникальные индексы гипертаблиц TimescaleDB обязаны его содержать.
+	DedupKey *string `gorm:"size:64;uniqueIndex:idx_histories_dedup,priority:1"`
 }
 
-// Структура надстройки над GORM
+// Структура надстройки над GORM, общая для SQLite и PostgreSQL
 type DB struct {
 	Conn *gorm.DB
-}
 
-// Инициализация БД с режимом WAL и запуском миграции
-func Initialized(dbFile string) (*DB, error) {
-	// Подключаемся к БД с помощью API GORM
-	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{
-		Logger: logger.NewGormLogger(),
-	})
-	if err != nil {
-		return nil, err
-	}
+	// Диалект: sqlite, postgres или timescale
+	dialect string
 
-	// Открываем БД для ее последующего конфигурирования
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, err
-	}
+	// Кэш типов контролов из meta: ключ device/parameter, пустая строка — meta нет
+	controlTypes sync.Map
 
-	// Активируем режим WAL и другие настройки
-	_, err = sqlDB.Exec(`
-		PRAGMA journal_mode=WAL;
-		PRAGMA synchronous=NORMAL;
-		PRAGMA busy_timeout=5000;
-		PRAGMA journal_size_limit=1000000;
-		PRAGMA cache_size=-10000;  -- 10MB cache
-		PRAGMA foreign_keys=ON;
-	`)
-	if err != nil {
-		return nil, err
-	}
+	// Уровни агрегированной истории
+	rollupTiers []RollupTier
+
+	// Срок хранения сырой истории в днях; 0 — не задан
+	historyRetentionDays int
 
-	// Настройка подключений:
-	// максимальное количество параллельных подключений(защита от SQL Busy),
-	// максимальное количество застывших соединений
-	// Время жизни одного соединения(освобождение ресурсов)
-	sqlDB.SetMaxOpenConns(1)
-	sqlDB.SetMaxIdleConns(1)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	// Правила хранения истории по маскам рядов
+	retention policy.RetentionRules
+
+	// Правила записи истории: только изменения, зона нечувствительности, интервалы
+	recorder *recorder
+}
+
+// newDB запускает миграции, общие для всех диалектов
+func newDB(conn *gorm.DB, dialect string) (*DB, error) {
+	// Раньше текущие значения могли задублироваться при гонке воркеров —
+	// чистим дубли перед созданием уникального индекса
+	if conn.Migrator().HasTable(&CurrentValue{}) && !conn.Migrator().HasIndex(&CurrentValue{}, "idx_current_values_series") {
+		if err := conn.Exec(`DELETE FROM current_values WHERE id NOT IN
+			(SELECT MAX(id) FROM current_values GROUP BY device, parameter)`).Error; err != nil {
+			return nil, err
+		}
+	}
 
 	// Запуск миграции на соответствие БД со структурами - создание таблиц если их нет, в моем случае
-	err = db.AutoMigrate(&CurrentValue{}, &History{})
+	err := conn.AutoMigrate(&CurrentValue{}, &History{}, &Device{}, &Control{})
 	if err != nil {
 		return nil, err
 	}
 
-	logger.Log.Info().
-		Str("component", "storage").
-		Str("db_file", dbFile).
-		Msg("Database initialized with WAL mode")
+	// Таблицы агрегированной истории
+	tiers := append([]RollupTier(nil), defaultRollupTiers...)
+	if err := migrateRollups(conn, tiers); err != nil {
+		return nil, err
+	}
 
-	return &DB{Conn: db}, nil
+	return &DB{
+		Conn:        conn,
+		dialect:     dialect,
+		rollupTiers: tiers,
+		recorder:    newRecorder(),
+	}, nil
 }
 
 // Функция обновляет текущее значение и добавляет строку истории за одну транзакцию
 func (db *DB) SaveValue(device, parameter, value string) error {
-	now := time.Now().UTC().Truncate(time.Millisecond)
-
-	return db.Conn.Transaction(func(tx *gorm.DB) error {
-		// Upsert current value
-		var curr CurrentValue
-		result := tx.
-			Where(CurrentValue{Device: device, Parameter: parameter}).
-			Attrs(CurrentValue{Value: value, UpdatedAt: now}).
-			FirstOrCreate(&curr)
-		if result.Error != nil {
-			return result.Error
-		}
+	return db.SaveBatch([]Sample{{
+		Device:    device,
+		Parameter: parameter,
+		Value:     value,
+		Timestamp: time.Now(),
+	}})
+}
 
-		// If record existed, update it
-		if curr.ID != 0 && (curr.Value != value || curr.UpdatedAt.Before(now)) {
-			curr.Value = value
-			curr.UpdatedAt = now
-			if err := tx.Save(&curr).Error; err != nil {
-				return err
-			}
-		}
+// SetRetentionRules задает правила хранения истории; вызывать до начала записи
+func (db *DB) SetRetentionRules(rules policy.RetentionRules) {
+	db.retention = rules
+}
 
-		// Добавление записи в историю
-		return tx.Create(&History{
-			Device:    device,
-			Parameter: parameter,
-			Value:     value,
-			Timestamp: now,
-		}).Error
-	})
+// SetRecordingRules задает правила записи истории по маскам рядов
+func (db *DB) SetRecordingRules(rules policy.RecordingRules) {
+	db.recorder.setRules(rules)
 }
 
 // CleanOldHistory deletes history entries older than retentionDays.
+// Series matched by a retention rule use the rule instead: their own age limit,
+// no limit for "forever", or full removal for "none".
 func (db *DB) CleanOldHistory(retentionDays int) error {
 	cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays).Truncate(time.Millisecond)
-	return db.Conn.Transaction(func(tx *gorm.DB) error {
-		return tx.Where("timestamp < ?", cutoff).Delete(&History{}).Error
+	if len(db.retention) == 0 {
+		return db.Conn.Transaction(func(tx *gorm.DB) error {
+			return tx.Where("timestamp < ?", cutoff).Delete(&History{}).Error
+		})
+	}
+
+	// Список рядов берем из текущих значений: каждая запись истории обновляет и их
+	var series []CurrentValue
+	if err := db.Conn.Select("device, parameter").Find(&series).Error; err != nil {
+		return err
+	}
+
+	for _, s := range series {
+		seriesCutoff := cutoff
+		if r, ok := db.retention.Lookup(s.Device, s.Parameter); ok {
+			switch {
+			case r.Forever:
+				continue
+			case r.Skip:
+				seriesCutoff = time.Now().UTC().Add(time.Hour)
+			default:
+				seriesCutoff = time.Now().UTC().AddDate(0, 0, -r.Days).Truncate(time.Millisecond)
+			}
+		}
+
+		if err := db.Conn.
+			Where("device = ? AND parameter = ? AND timestamp < ?", s.Device, s.Parameter, seriesCutoff).
+			Delete(&History{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция возвращающая историю значений в хронологическом порядке.
+// Условия conds фильтруют по числовому значению, нечисловые строки при этом отбрасываются.
+func (db *DB) GetHistory(device, parameter string, startMs, endMs int64, conds ...ValueCondition) ([]History, error) {
+	return db.QueryHistory(HistoryQuery{
+		Device:    device,
+		Parameter: parameter,
+		StartMs:   startMs,
+		EndMs:     endMs,
+		Conds:     conds,
 	})
 }
 
-// Функция возвращающая историю значений в хронологическом порядке
-func (db *DB) GetHistory(device, parameter string, startMs, endMs int64) ([]History, error) {
+// SeriesFilter выбирает ряды по устройству и контролу; пустое поле подходит под любое значение
+type SeriesFilter struct {
+	Device    string
+	Parameter string
+}
+
+// GetHistoryRange возвращает историю рядов, подходящих под любой из фильтров (пустой список —
+// все ряды), за период (startMs, endMs] в порядке записи, не больше limit строк
+func (db *DB) GetHistoryRange(series []SeriesFilter, startMs, endMs int64, limit int) ([]History, error) {
 	startTime := time.UnixMilli(startMs).UTC().Truncate(time.Millisecond)
 	endTime := time.UnixMilli(endMs).UTC().Truncate(time.Millisecond)
 
+	tx := db.Conn.Where("timestamp > ? AND timestamp <= ?", startTime, endTime)
+	if len(series) > 0 {
+		var (
+			conds []string
+			args  []any
+		)
+		for _, f := range series {
+			var parts []string
+			if f.Device != "" {
+				parts = append(parts, "device = ?")
+				args = append(args, f.Device)
+			}
+			if f.Parameter != "" {
+				parts = append(parts, "parameter = ?")
+				args = append(args, f.Parameter)
+			}
+			if len(parts) == 0 {
+				// Фильтр без полей подходит под все ряды
+				conds = nil
+				break
+			}
+			conds = append(conds, "("+strings.Join(parts, " AND ")+")")
+		}
+		if len(conds) > 0 {
+			tx = tx.Where(strings.Join(conds, " OR "), args...)
+		}
+	}
+
 	var history []History
-	err := db.Conn.
-		Where("device = ? AND parameter = ? AND timestamp BETWEEN ? AND ?",
-			device, parameter, startTime, endTime).
-		Order("timestamp ASC").
+	err := tx.
+		Order("timestamp ASC, id ASC").
+		Limit(limit).
 		Find(&history).Error
 
 	return history, err
 }
 
+// GetCurrentValues возвращает текущие значения всех рядов
+func (db *DB) GetCurrentValues() ([]CurrentValue, error) {
+	var values []CurrentValue
+	err := db.Conn.Order("device ASC, parameter ASC").Find(&values).Error
+	return values, err
+}
+
 // Закрываем БД
 func (db *DB) Close() error {
 	sqlDB, err := db.Conn.DB()
@@ -147,6 +223,8 @@ func (db *DB) Close() error {
 	}
 
 	// Переносим данные с WAL-журнала в основную БД, игнорируя ошибку переноса
-	_, _ = sqlDB.Exec("PRAGMA wal_checkpoint(TRUNCATE);")
+	if db.dialect == DialectSQLite {
+		_, _ = sqlDB.Exec("PRAGMA wal_checkpoint(TRUNCATE);")
+	}
 	return sqlDB.Close()
 }
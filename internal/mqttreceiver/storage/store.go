@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"brutus/internal/mqttreceiver/policy"
+)
+
+// Диалекты хранилища, выбираются через DB_DRIVER
+const (
+	DialectSQLite    = "sqlite"
+	DialectPostgres  = "postgres"
+	DialectTimescale = "timescale"
+)
+
+// Store — хранилище значений, которым пользуются воркеры приема и gRPC сервер.
+// Реализации: SQLite (Initialized) и PostgreSQL/TimescaleDB (OpenPostgres).
+type Store interface {
+	// Запись значений
+	SaveValue(device, parameter, value string) error
+	SaveBatch(samples []Sample) error
+
+	// Чтение
+	GetHistory(device, parameter string, startMs, endMs int64, conds ...ValueCondition) ([]History, error)
+	QueryHistory(q HistoryQuery) ([]History, error)
+	StreamHistory(q HistoryQuery, chunkSize int, fn func([]History) error) error
+	GetHistoryRange(series []SeriesFilter, startMs, endMs int64, limit int) ([]History, error)
+	GetAggregatedHistory(device, parameter string, startMs, endMs int64, bucket time.Duration) ([]Bucket, error)
+	GetCurrentValues() ([]CurrentValue, error)
+
+	// Реестр устройств из meta-топиков
+	SaveMeta(device, control, key, value string) error
+	GetDevices(device string) ([]Device, error)
+	GetControl(device, control string) (*Control, error)
+
+	// Политики хранения и обслуживание
+	SetRetentionRules(rules policy.RetentionRules)
+	SetRecordingRules(rules policy.RecordingRules)
+	SetHistoryRetention(days int)
+	SetRollupRetention(days map[string]int)
+	CleanOldHistory(retentionDays int) error
+	RunRollups(now time.Time) error
+	CleanOldRollups() error
+
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
+
+// Open открывает хранилище выбранного диалекта.
+// Для SQLite dsn — путь к файлу, для PostgreSQL — строка подключения.
+func Open(dialect, dsn string, maxOpenConns int) (Store, error) {
+	switch dialect {
+	case DialectSQLite:
+		return Initialized(dsn, maxOpenConns)
+	case DialectPostgres:
+		return OpenPostgres(dsn, false, maxOpenConns)
+	case DialectTimescale:
+		return OpenPostgres(dsn, true, maxOpenConns)
+	}
+	return nil, fmt.Errorf("unknown storage dialect %q", dialect)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Типы значений, определяемые при записи
+const (
+	ValueTypeNumeric = "numeric"
+	ValueTypeBoolean = "boolean"
+	ValueTypeString  = "string"
+	ValueTypeJSON    = "json"
+)
+
+// Классификация по типу контрола Wiren Board; неизвестные типы считаем числовыми
+var controlValueTypes = map[string]string{
+	"switch":     ValueTypeBoolean,
+	"pushbutton": ValueTypeBoolean,
+	"alarm":      ValueTypeBoolean,
+	"text":       ValueTypeString,
+	"rgb":        ValueTypeString,
+}
+
+// ClassifyValue определяет тип значения и его числовое представление.
+// controlType — тип из meta контрола, пустой если meta неизвестна.
+func ClassifyValue(value, controlType string) (string, *float64) {
+	if controlType != "" {
+		vt, ok := controlValueTypes[controlType]
+		if !ok {
+			vt = ValueTypeNumeric
+		}
+		switch vt {
+		case ValueTypeBoolean:
+			if f, ok := parseBool(value); ok {
+				return vt, &f
+			}
+		case ValueTypeNumeric:
+			if f, ok := parseNumber(value); ok {
+				return vt, &f
+			}
+		case ValueTypeString:
+			return vt, nil
+		}
+		// Значение не соответствует meta — определяем по содержимому
+	}
+
+	if f, ok := parseNumber(value); ok {
+		return ValueTypeNumeric, &f
+	}
+	if f, ok := parseBool(value); ok {
+		return ValueTypeBoolean, &f
+	}
+	trimmed := strings.TrimSpace(value)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return ValueTypeJSON, nil
+	}
+	return ValueTypeString, nil
+}
+
+func parseNumber(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+func parseBool(value string) (float64, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "on":
+		return 1, true
+	case "0", "false", "off":
+		return 0, true
+	}
+	return 0, false
+}
+
+// ValueCondition — условие на числовое значение истории, например "> 30"
+type ValueCondition struct {
+	Op    string
+	Value float64
+}
+
+var (
+	conditionRe = regexp.MustCompile(`^(?i:value)?\s*(>=|<=|!=|==|=|>|<)\s*(\S+)$`)
+	andRe       = regexp.MustCompile(`(?i)\s+and\s+`)
+)
+
+// ParseValueFilter разбирает выражение вида "value > 30" или "> 10 and <= 20"
+func ParseValueFilter(expr string) ([]ValueCondition, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	var conds []ValueCondition
+	for _, part := range andRe.Split(expr, -1) {
+		m := conditionRe.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return nil, fmt.Errorf("invalid value filter %q", part)
+		}
+		v, ok := parseNumber(m[2])
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q in value filter", m[2])
+		}
+		op := m[1]
+		switch op {
+		case "==":
+			op = "="
+		case "!=":
+			op = "<>"
+		}
+		conds = append(conds, ValueCondition{Op: op, Value: v})
+	}
+	return conds, nil
+}
@@ -0,0 +1,83 @@
+// internal/mqttreceiver/storage/values_test.go
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyValue(t *testing.T) {
+	num := func(f float64) *float64 { return &f }
+	show := func(f *float64) any {
+		if f == nil {
+			return nil
+		}
+		return *f
+	}
+	tests := []struct {
+		value       string
+		controlType string
+		wantType    string
+		wantNum     *float64
+	}{
+		{"21.5", "", ValueTypeNumeric, num(21.5)},
+		{" -3 ", "", ValueTypeNumeric, num(-3)},
+		{"NaN", "", ValueTypeString, nil},
+		{"Inf", "", ValueTypeString, nil},
+		{"on", "", ValueTypeBoolean, num(1)},
+		{"False", "", ValueTypeBoolean, num(0)},
+		{`{"a":1}`, "", ValueTypeJSON, nil},
+		{"[1,2", "", ValueTypeString, nil},
+		{"hello", "", ValueTypeString, nil},
+		{"1", "switch", ValueTypeBoolean, num(1)},
+		{"12", "text", ValueTypeString, nil},
+		{"255;0;0", "rgb", ValueTypeString, nil},
+		{"42", "temperature", ValueTypeNumeric, num(42)},
+		// Значение не соответствует meta — тип по содержимому
+		{"error", "temperature", ValueTypeString, nil},
+		{"2", "switch", ValueTypeNumeric, num(2)},
+	}
+	for _, tt := range tests {
+		gotType, gotNum := ClassifyValue(tt.value, tt.controlType)
+		if gotType != tt.wantType || !reflect.DeepEqual(gotNum, tt.wantNum) {
+			t.Errorf("ClassifyValue(%q, %q) = %s, %v, want %s, %v",
+				tt.value, tt.controlType, gotType, show(gotNum), tt.wantType, show(tt.wantNum))
+		}
+	}
+}
+
+func TestParseValueFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    []ValueCondition
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"   ", nil, false},
+		{"value > 30", []ValueCondition{{">", 30}}, false},
+		{"VALUE>=30", []ValueCondition{{">=", 30}}, false},
+		{"< -1.5", []ValueCondition{{"<", -1.5}}, false},
+		{"== 1", []ValueCondition{{"=", 1}}, false},
+		{"= 1", []ValueCondition{{"=", 1}}, false},
+		{"!= 0", []ValueCondition{{"<>", 0}}, false},
+		{"> 10 and <= 20", []ValueCondition{{">", 10}, {"<=", 20}}, false},
+		{"value > 10 AND value < 20", []ValueCondition{{">", 10}, {"<", 20}}, false},
+		{"value", nil, true},
+		{"> abc", nil, true},
+		{"> NaN", nil, true},
+		{"> 1 2", nil, true},
+		{"> 10 or < 5", nil, true},
+		{"> 10 and", nil, true},
+		{"value; DROP TABLE history", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseValueFilter(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseValueFilter(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseValueFilter(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// internal/mqttreceiver/tlsutil/tlsutil.go
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"brutus/internal/mqttreceiver/logger"
+)
+
+// Как часто проверять файлы сертификатов на изменение
+const reloadCheckInterval = 10 * time.Second
+
+// ClientAuth — проверка клиентских сертификатов на сервере
+type ClientAuth string
+
+const (
+	ClientAuthNone     ClientAuth = "none"     // сертификат клиента не запрашивается
+	ClientAuthOptional ClientAuth = "optional" // проверяется, если клиент его прислал
+	ClientAuthRequire  ClientAuth = "require"  // без проверенного сертификата соединение отклоняется
+)
+
+// ParseClientAuth проверяет режим проверки клиентских сертификатов
+func ParseClientAuth(s string) (ClientAuth, error) {
+	switch c := ClientAuth(s); c {
+	case ClientAuthNone, ClientAuthOptional, ClientAuthRequire:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown client auth mode %q", s)
+}
+
+// Reloader держит сертификат, ключ и CA и перечитывает их с диска при изменении файлов,
+// так что обновленный сертификат подхватывается без перезапуска
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+// NewReloader загружает файлы; пустые пути допустимы (например, только CA у клиента)
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) load() error {
+	var cert *tls.Certificate
+	if r.certFile != "" || r.keyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate: %v", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("read CA: %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA file %s contains no certificates", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool = cert, pool
+	r.modTimes = r.stat()
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) stat() [3]time.Time {
+	var times [3]time.Time
+	for i, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+	return times
+}
+
+// maybeReload перечитывает файлы, если они изменились; ошибка оставляет прежние сертификаты
+func (r *Reloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) >= reloadCheckInterval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	times := r.stat()
+	r.mu.Lock()
+	changed := times != r.modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		logger.Log.Error().Str("component", "tls").Err(err).Msg("Failed to reload certificates, keeping previous ones")
+		return
+	}
+	logger.Log.Info().Str("component", "tls").Str("cert_file", r.certFile).Msg("Certificates reloaded")
+}
+
+// Certificate возвращает текущий сертификат
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// CAPool возвращает текущий набор доверенных CA; nil — системный
+func (r *Reloader) CAPool() *x509.CertPool {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ClientConfig строит конфигурацию TLS клиента для сервера serverName (имя или IP-адрес):
+// сертификат отдается из Reloader при каждом подключении. Цепочка сервера проверяется
+// по CA, актуальному на момент рукопожатия, так что смена CA брокера подхватывается
+// при переподключении; без файла CA используются системные.
+func (r *Reloader) ClientConfig(serverName string, insecureSkipVerify bool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if r.caFile != "" && !insecureSkipVerify {
+		// Стандартная проверка взяла бы RootCAs, зафиксированные при создании конфигурации.
+		// Имя берем из настроек: для IP-адреса SNI не отправляется и cs.ServerName пуст
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return r.verifyServer(serverName, cs)
+		}
+	}
+	if r.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+	return cfg
+}
+
+// verifyServer проверяет сертификат сервера по текущему набору CA и его имя:
+// DNS-имя — по SAN-именам, IP-адрес — по SAN-адресам сертификата
+func (r *Reloader) verifyServer(serverName string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+	if serverName == "" {
+		return fmt.Errorf("server name is required to verify the server certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         r.CAPool(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	leaf := cs.PeerCertificates[0]
+	if _, err := leaf.Verify(opts); err != nil {
+		return err
+	}
+	if ip := net.ParseIP(strings.Trim(serverName, "[]")); ip != nil {
+		for _, certIP := range leaf.IPAddresses {
+			if certIP.Equal(ip) {
+				return nil
+			}
+		}
+		return fmt.Errorf("x509: certificate is not valid for IP %s", ip)
+	}
+	return leaf.VerifyHostname(serverName)
+}
+
+// ServerConfig строит конфигурацию TLS сервера; сертификат и CA клиентов берутся
+// из Reloader при каждом рукопожатии
+func (r *Reloader) ServerConfig(clientAuth ClientAuth) (*tls.Config, error) {
+	if r.certFile == "" {
+		return nil, fmt.Errorf("server certificate is required")
+	}
+	if clientAuth != ClientAuthNone && r.caFile == "" {
+		return nil, fmt.Errorf("client certificate verification requires a CA file")
+	}
+
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := base.Clone()
+			cfg.Certificates = []tls.Certificate{*r.Certificate()}
+			switch clientAuth {
+			case ClientAuthOptional:
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				cfg.ClientCAs = r.CAPool()
+			case ClientAuthRequire:
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = r.CAPool()
+			}
+			return cfg, nil
+		},
+	}, nil
+}
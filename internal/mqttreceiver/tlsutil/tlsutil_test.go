@@ -0,0 +1,183 @@
+// internal/mqttreceiver/tlsutil/tlsutil_test.go
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает серверный сертификат на имена и IP-адреса
+func (ca *testCA) issue(t *testing.T, names []string, ips []net.IP) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "broker"},
+		DNSNames:     names,
+		IPAddresses:  ips,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serve запускает TLS-сервер на 127.0.0.1 и возвращает его порт
+func serve(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func dial(port string, cfg *tls.Config) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", net.JoinHostPort("127.0.0.1", port), cfg)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestClientConfigVerifiesServer(t *testing.T) {
+	ca := newTestCA(t, "test-ca")
+	other := newTestCA(t, "other-ca")
+	port := serve(t, ca.issue(t, []string{"broker.local"}, []net.IP{net.IPv4(127, 0, 0, 1)}))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, ca.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		serverName string
+		insecure   bool
+		ok         bool
+	}{
+		{"IP address in SAN", "127.0.0.1", false, true},
+		{"DNS name in SAN", "broker.local", false, true},
+		{"IP address not in SAN", "127.0.0.2", false, false},
+		{"DNS name not in SAN", "other.local", false, false},
+		{"no server name", "", false, false},
+		{"insecure", "other.local", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dial(port, r.ClientConfig(tt.serverName, tt.insecure))
+			if (err == nil) != tt.ok {
+				t.Fatalf("dial error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+
+	// Сертификат чужого CA не принимается
+	otherPort := serve(t, other.issue(t, nil, []net.IP{net.IPv4(127, 0, 0, 1)}))
+	if err := dial(otherPort, r.ClientConfig("127.0.0.1", false)); err == nil {
+		t.Fatal("certificate of an unknown CA accepted")
+	}
+}
+
+// Смена файла CA подхватывается уже созданной конфигурацией
+func TestClientConfigReloadsCA(t *testing.T) {
+	oldCA := newTestCA(t, "old-ca")
+	newCA := newTestCA(t, "new-ca")
+	port := serve(t, newCA.issue(t, nil, []net.IP{net.IPv4(127, 0, 0, 1)}))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, oldCA.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := r.ClientConfig("127.0.0.1", false)
+	if err := dial(port, cfg); err == nil {
+		t.Fatal("certificate of the new CA accepted before rotation")
+	}
+
+	if err := os.WriteFile(caFile, newCA.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(caFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+
+	if err := dial(port, cfg); err != nil {
+		t.Fatalf("dial after CA rotation: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// internal/mqttreceiver/topic/topic.go
+package topic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Плейсхолдеры, поддерживаемые в шаблонах топиков
+const (
+	DevicePlaceholder  = "{device_id}"
+	ControlPlaceholder = "{control_id}"
+)
+
+// Pattern — разобранный шаблон топика, например /devices/{device_id}/controls/{control_id}.
+// Кроме плейсхолдеров допускаются MQTT-маски: "+" (один уровень) и "#" (остаток топика).
+type Pattern struct {
+	template string
+	segments []string
+}
+
+// Parse разбирает шаблон и проверяет, что в нем есть оба плейсхолдера
+func Parse(template string) (*Pattern, error) {
+	template = strings.TrimSpace(template)
+	if template == "" {
+		return nil, fmt.Errorf("empty topic pattern")
+	}
+
+	segments := Split(template)
+	var devices, controls int
+	for i, seg := range segments {
+		switch {
+		case seg == DevicePlaceholder:
+			devices++
+		case seg == ControlPlaceholder:
+			controls++
+		case seg == "#":
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("topic pattern %q: '#' must be the last level", template)
+			}
+		case seg == "+":
+		case strings.ContainsAny(seg, "{}+#"):
+			return nil, fmt.Errorf("topic pattern %q: unsupported level %q", template, seg)
+		}
+	}
+	if devices != 1 || controls != 1 {
+		return nil, fmt.Errorf("topic pattern %q: must contain %s and %s exactly once",
+			template, DevicePlaceholder, ControlPlaceholder)
+	}
+
+	return &Pattern{template: template, segments: segments}, nil
+}
+
+// ParseList разбирает список шаблонов, разделенных запятыми
+func ParseList(list string) ([]*Pattern, error) {
+	var patterns []*Pattern
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		p, err := Parse(item)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no topic patterns given")
+	}
+	return patterns, nil
+}
+
+// String возвращает исходный шаблон
+func (p *Pattern) String() string {
+	return p.template
+}
+
+// Match извлекает устройство и контрол из входящего топика.
+// Ведущий и повторяющиеся слеши игнорируются, как и раньше в обработчике MQTT.
+func (p *Pattern) Match(t string) (device, control string, ok bool) {
+	parts := Split(t)
+	for i, seg := range p.segments {
+		if seg == "#" {
+			return device, control, true
+		}
+		if i >= len(parts) {
+			return "", "", false
+		}
+		switch seg {
+		case DevicePlaceholder:
+			device = parts[i]
+		case ControlPlaceholder:
+			control = parts[i]
+		case "+":
+		default:
+			if seg != parts[i] {
+				return "", "", false
+			}
+		}
+	}
+	if len(parts) != len(p.segments) {
+		return "", "", false
+	}
+	return device, control, true
+}
+
+// CanFormat сообщает, можно ли построить по шаблону конкретный топик (нет масок)
+func (p *Pattern) CanFormat() bool {
+	for _, seg := range p.segments {
+		if seg == "+" || seg == "#" {
+			return false
+		}
+	}
+	return true
+}
+
+// Format подставляет устройство и контрол в шаблон для публикации
+func (p *Pattern) Format(device, control string) string {
+	return strings.NewReplacer(
+		DevicePlaceholder, device,
+		ControlPlaceholder, control,
+	).Replace(p.template)
+}
+
+// Filter возвращает MQTT-фильтр для подписки: плейсхолдеры заменяются на "+"
+func (p *Pattern) Filter() string {
+	return strings.NewReplacer(
+		DevicePlaceholder, "+",
+		ControlPlaceholder, "+",
+	).Replace(p.template)
+}
+
+// Split разбивает топик на уровни, отбрасывая пустые
+func Split(t string) []string {
+	var parts []string
+	for _, part := range strings.Split(t, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// ValidateFilter проверяет MQTT-фильтр: "+" занимает уровень целиком, "#" — только последним уровнем
+func ValidateFilter(filter string) error {
+	if strings.TrimSpace(filter) == "" {
+		return fmt.Errorf("empty filter")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#":
+			if i != len(levels)-1 {
+				return fmt.Errorf("filter %q: '#' must be the last level", filter)
+			}
+		case level == "+":
+		case strings.ContainsAny(level, "+#"):
+			return fmt.Errorf("filter %q: wildcard must occupy an entire level", filter)
+		}
+	}
+	return nil
+}
+
+// MatchFilter проверяет топик по MQTT-фильтру с масками "+" и "#"
+func MatchFilter(filter, t string) bool {
+	levels := strings.Split(filter, "/")
+	parts := strings.Split(t, "/")
+	for i, level := range levels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if level != "+" && level != parts[i] {
+			return false
+		}
+	}
+	return len(parts) == len(levels)
+}
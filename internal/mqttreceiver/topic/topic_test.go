@@ -0,0 +1,121 @@
+// internal/mqttreceiver/topic/topic_test.go
+package topic
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		template string
+		ok       bool
+	}{
+		{"/devices/{device_id}/controls/{control_id}", true},
+		{"  /devices/{device_id}/controls/{control_id}  ", true},
+		{"/devices/{device_id}/+/{control_id}", true},
+		{"{device_id}/{control_id}/#", true},
+		{"", false},
+		{"/devices/{device_id}/controls", false},
+		{"/devices/{device_id}/{device_id}/{control_id}", false},
+		{"/devices/{device_id}/#/{control_id}", false},
+		{"/devices/{device_id}/a+/{control_id}", false},
+		{"/devices/{device}/controls/{control_id}", false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.template)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error = %v, want ok = %v", tt.template, err, tt.ok)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		template string
+		topic    string
+		device   string
+		control  string
+		ok       bool
+	}{
+		{"/devices/{device_id}/controls/{control_id}", "/devices/wb-gpio/controls/A1_OUT", "wb-gpio", "A1_OUT", true},
+		{"/devices/{device_id}/controls/{control_id}", "devices//wb-gpio/controls/A1_OUT", "wb-gpio", "A1_OUT", true},
+		{"/devices/{device_id}/controls/{control_id}", "/devices/wb-gpio/controls/A1_OUT/on", "", "", false},
+		{"/devices/{device_id}/controls/{control_id}", "/devices/wb-gpio/controls", "", "", false},
+		{"/devices/{device_id}/controls/{control_id}", "/devices/wb-gpio/meta/A1_OUT", "", "", false},
+		{"/devices/{device_id}/+/{control_id}", "/devices/wb-gpio/controls/A1_OUT", "wb-gpio", "A1_OUT", true},
+		{"/devices/{device_id}/+/{control_id}", "/devices/wb-gpio/A1_OUT", "", "", false},
+		{"{device_id}/{control_id}/#", "wb-adc/A1", "wb-adc", "A1", true},
+		{"{device_id}/{control_id}/#", "wb-adc/A1/raw/x", "wb-adc", "A1", true},
+		{"{device_id}/{control_id}/#", "wb-adc", "", "", false},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.template, err)
+		}
+		device, control, ok := p.Match(tt.topic)
+		if device != tt.device || control != tt.control || ok != tt.ok {
+			t.Errorf("%q.Match(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.template, tt.topic, device, control, ok, tt.device, tt.control, tt.ok)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		template  string
+		canFormat bool
+		topic     string
+		filter    string
+	}{
+		{"/devices/{device_id}/controls/{control_id}/on", true, "/devices/wb-gpio/controls/A1_OUT/on", "/devices/+/controls/+/on"},
+		{"/devices/{device_id}/+/{control_id}", false, "", "/devices/+/+/+"},
+		{"{device_id}/{control_id}/#", false, "", "+/+/#"},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.template, err)
+		}
+		if got := p.CanFormat(); got != tt.canFormat {
+			t.Errorf("%q.CanFormat() = %v, want %v", tt.template, got, tt.canFormat)
+		}
+		if tt.canFormat {
+			if got := p.Format("wb-gpio", "A1_OUT"); got != tt.topic {
+				t.Errorf("%q.Format() = %q, want %q", tt.template, got, tt.topic)
+			}
+		}
+		if got := p.Filter(); got != tt.filter {
+			t.Errorf("%q.Filter() = %q, want %q", tt.template, got, tt.filter)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		valid  bool
+		match  bool
+	}{
+		{"#", "wb-gpio/A1_OUT", true, true},
+		{"wb-gpio/#", "wb-gpio/A1_OUT", true, true},
+		{"wb-gpio/A1_OUT/#", "wb-gpio/A1_OUT", true, true},
+		{"+/A1_OUT", "wb-gpio/A1_OUT", true, true},
+		{"+/A1_OUT", "wb-gpio/A2_OUT", true, false},
+		{"wb-gpio/+", "wb-gpio", true, false},
+		{"wb-gpio", "wb-gpio/A1_OUT", true, false},
+		{"wb-gpio/#/A1", "", false, false},
+		{"wb-gpio/A+", "", false, false},
+		{" ", "", false, false},
+	}
+	for _, tt := range tests {
+		err := ValidateFilter(tt.filter)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateFilter(%q) error = %v, want valid = %v", tt.filter, err, tt.valid)
+		}
+		if tt.valid {
+			if got := MatchFilter(tt.filter, tt.topic); got != tt.match {
+				t.Errorf("MatchFilter(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.match)
+			}
+		}
+	}
+}
@@ -23,12 +23,191 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Что делать с живым значением, когда очередь клиента заполнена
+type SlowConsumerPolicy int32
+
+const (
+	SlowConsumerPolicy_SLOW_CONSUMER_POLICY_UNSPECIFIED SlowConsumerPolicy = 0 // политика сервера
+	SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DROP_NEWEST SlowConsumerPolicy = 1 // выбросить входящее значение
+	SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DROP_OLDEST SlowConsumerPolicy = 2 // выбросить самое старое значение в очереди
+	SlowConsumerPolicy_SLOW_CONSUMER_POLICY_COALESCE    SlowConsumerPolicy = 3 // оставить в очереди только последнее значение каждого ряда
+	SlowConsumerPolicy_SLOW_CONSUMER_POLICY_DISCONNECT  SlowConsumerPolicy = 4 // закрыть поток с RESOURCE_EXHAUSTED
+)
+
+// Enum value maps for SlowConsumerPolicy.
+var (
+	SlowConsumerPolicy_name = map[int32]string{
+		0: "SLOW_CONSUMER_POLICY_UNSPECIFIED",
+		1: "SLOW_CONSUMER_POLICY_DROP_NEWEST",
+		2: "SLOW_CONSUMER_POLICY_DROP_OLDEST",
+		3: "SLOW_CONSUMER_POLICY_COALESCE",
+		4: "SLOW_CONSUMER_POLICY_DISCONNECT",
+	}
+	SlowConsumerPolicy_value = map[string]int32{
+		"SLOW_CONSUMER_POLICY_UNSPECIFIED": 0,
+		"SLOW_CONSUMER_POLICY_DROP_NEWEST": 1,
+		"SLOW_CONSUMER_POLICY_DROP_OLDEST": 2,
+		"SLOW_CONSUMER_POLICY_COALESCE":    3,
+		"SLOW_CONSUMER_POLICY_DISCONNECT":  4,
+	}
+)
+
+func (x SlowConsumerPolicy) Enum() *SlowConsumerPolicy {
+	p := new(SlowConsumerPolicy)
+	*p = x
+	return p
+}
+
+func (x SlowConsumerPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SlowConsumerPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_brutus_proto_enumTypes[0].Descriptor()
+}
+
+func (SlowConsumerPolicy) Type() protoreflect.EnumType {
+	return &file_proto_brutus_proto_enumTypes[0]
+}
+
+func (x SlowConsumerPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SlowConsumerPolicy.Descriptor instead.
+func (SlowConsumerPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{0}
+}
+
+// Итог выполнения команды
+type CommandStatus int32
+
+const (
+	CommandStatus_COMMAND_STATUS_UNSPECIFIED CommandStatus = 0
+	CommandStatus_COMMAND_STATUS_APPLIED     CommandStatus = 1 // устройство вернуло новое состояние
+	CommandStatus_COMMAND_STATUS_TIMED_OUT   CommandStatus = 2 // подтверждение не пришло за таймаут
+	CommandStatus_COMMAND_STATUS_REJECTED    CommandStatus = 3 // контрол readonly, ошибка публикации или записи, слишком много команд в работе
+)
+
+// Enum value maps for CommandStatus.
+var (
+	CommandStatus_name = map[int32]string{
+		0: "COMMAND_STATUS_UNSPECIFIED",
+		1: "COMMAND_STATUS_APPLIED",
+		2: "COMMAND_STATUS_TIMED_OUT",
+		3: "COMMAND_STATUS_REJECTED",
+	}
+	CommandStatus_value = map[string]int32{
+		"COMMAND_STATUS_UNSPECIFIED": 0,
+		"COMMAND_STATUS_APPLIED":     1,
+		"COMMAND_STATUS_TIMED_OUT":   2,
+		"COMMAND_STATUS_REJECTED":    3,
+	}
+)
+
+func (x CommandStatus) Enum() *CommandStatus {
+	p := new(CommandStatus)
+	*p = x
+	return p
+}
+
+func (x CommandStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CommandStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_brutus_proto_enumTypes[1].Descriptor()
+}
+
+func (CommandStatus) Type() protoreflect.EnumType {
+	return &file_proto_brutus_proto_enumTypes[1]
+}
+
+func (x CommandStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CommandStatus.Descriptor instead.
+func (CommandStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{1}
+}
+
+// Агрегатные функции для прореженной истории
+type Aggregate int32
+
+const (
+	Aggregate_AGGREGATE_UNSPECIFIED Aggregate = 0
+	Aggregate_AGGREGATE_MIN         Aggregate = 1
+	Aggregate_AGGREGATE_MAX         Aggregate = 2
+	Aggregate_AGGREGATE_AVG         Aggregate = 3
+	Aggregate_AGGREGATE_FIRST       Aggregate = 4
+	Aggregate_AGGREGATE_LAST        Aggregate = 5
+	Aggregate_AGGREGATE_COUNT       Aggregate = 6
+	Aggregate_AGGREGATE_SUM         Aggregate = 7
+)
+
+// Enum value maps for Aggregate.
+var (
+	Aggregate_name = map[int32]string{
+		0: "AGGREGATE_UNSPECIFIED",
+		1: "AGGREGATE_MIN",
+		2: "AGGREGATE_MAX",
+		3: "AGGREGATE_AVG",
+		4: "AGGREGATE_FIRST",
+		5: "AGGREGATE_LAST",
+		6: "AGGREGATE_COUNT",
+		7: "AGGREGATE_SUM",
+	}
+	Aggregate_value = map[string]int32{
+		"AGGREGATE_UNSPECIFIED": 0,
+		"AGGREGATE_MIN":         1,
+		"AGGREGATE_MAX":         2,
+		"AGGREGATE_AVG":         3,
+		"AGGREGATE_FIRST":       4,
+		"AGGREGATE_LAST":        5,
+		"AGGREGATE_COUNT":       6,
+		"AGGREGATE_SUM":         7,
+	}
+)
+
+func (x Aggregate) Enum() *Aggregate {
+	p := new(Aggregate)
+	*p = x
+	return p
+}
+
+func (x Aggregate) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Aggregate) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_brutus_proto_enumTypes[2].Descriptor()
+}
+
+func (Aggregate) Type() protoreflect.EnumType {
+	return &file_proto_brutus_proto_enumTypes[2]
+}
+
+func (x Aggregate) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Aggregate.Descriptor instead.
+func (Aggregate) EnumDescriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{2}
+}
+
 type Value struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
-	Parameter     string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
-	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
-	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // Unix timestamp in milliseconds
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Device    string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Parameter string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
+	Value     string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Timestamp int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // Unix timestamp in milliseconds
+	Result    *CommandResult         `protobuf:"bytes,5,opt,name=result,proto3" json:"result,omitempty"`        // заполнено, если сообщение — ответ на команду
+	Snapshot  bool                   `protobuf:"varint,6,opt,name=snapshot,proto3" json:"snapshot,omitempty"`   // значение из снимка текущих состояний; timestamp — время его обновления
+	Sequence  uint64                 `protobuf:"varint,7,opt,name=sequence,proto3" json:"sequence,omitempty"`   // сквозной возрастающий номер живого значения по всем рядам;
+	// 0 — снимок, ответ на команду или повтор из истории
+	Dropped       uint64 `protobuf:"varint,8,opt,name=dropped,proto3" json:"dropped,omitempty"` // сколько значений для этого клиента отброшено с прошлого сообщения
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -91,11 +270,41 @@ func (x *Value) GetTimestamp() int64 {
 	return 0
 }
 
+func (x *Value) GetResult() *CommandResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *Value) GetSnapshot() bool {
+	if x != nil {
+		return x.Snapshot
+	}
+	return false
+}
+
+func (x *Value) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Value) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
 type Command struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
 	Parameter     string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
 	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	RequestId     string                 `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // возвращается в CommandResult
+	Subscription  *Subscription          `protobuf:"bytes,5,opt,name=subscription,proto3" json:"subscription,omitempty"`            // если задано, сообщение меняет подписку, а не отправляет команду
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -151,31 +360,53 @@ func (x *Command) GetValue() string {
 	return ""
 }
 
-// Запрос истории параметров
-type HistoryRequest struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Device         string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
-	Parameter      string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
-	StartTimestamp int64                  `protobuf:"varint,3,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"` // начало периода (в миллисекундах)
-	EndTimestamp   int64                  `protobuf:"varint,4,opt,name=end_timestamp,json=endTimestamp,proto3" json:"end_timestamp,omitempty"`       // конец периода (в миллисекундах)
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+func (x *Command) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
 }
 
-func (x *HistoryRequest) Reset() {
-	*x = HistoryRequest{}
+func (x *Command) GetSubscription() *Subscription {
+	if x != nil {
+		return x.Subscription
+	}
+	return nil
+}
+
+// Подписка клиента DataExchange на часть значений.
+// Фильтры вида "device/parameter" с MQTT-масками: "wb-adc/+", "+/temperature", "wb-gpio/#", "#".
+// Каждое сообщение заменяет прежнюю подписку; пустой список — все значения.
+// После переподключения клиент передает since_sequence и/или since_timestamp последнего
+// полученного значения: сервер повторяет пропущенное и затем переходит к живым значениям.
+// Повтор из истории приходит с sequence 0; если после него не было живых значений
+// с номером, клиент продолжает по since_timestamp последнего полученного значения.
+type Subscription struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Filters            []string               `protobuf:"bytes,1,rep,name=filters,proto3" json:"filters,omitempty"`
+	Snapshot           bool                   `protobuf:"varint,2,opt,name=snapshot,proto3" json:"snapshot,omitempty"`                                   // перед живыми обновлениями прислать текущие значения подходящих рядов
+	SinceSequence      uint64                 `protobuf:"varint,3,opt,name=since_sequence,json=sinceSequence,proto3" json:"since_sequence,omitempty"`    // повтор из буфера сервера значений с номером больше заданного
+	SinceTimestamp     int64                  `protobuf:"varint,4,opt,name=since_timestamp,json=sinceTimestamp,proto3" json:"since_timestamp,omitempty"` // Unix timestamp in milliseconds; повтор из истории, если номера уже нет в буфере
+	SlowConsumerPolicy SlowConsumerPolicy     `protobuf:"varint,5,opt,name=slow_consumer_policy,json=slowConsumerPolicy,proto3,enum=brutus.SlowConsumerPolicy" json:"slow_consumer_policy,omitempty"`
+	BufferSize         uint32                 `protobuf:"varint,6,opt,name=buffer_size,json=bufferSize,proto3" json:"buffer_size,omitempty"` // размер очереди клиента; 0 — по умолчанию, ограничен настройкой сервера
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
 	mi := &file_proto_brutus_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *HistoryRequest) String() string {
+func (x *Subscription) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HistoryRequest) ProtoMessage() {}
+func (*Subscription) ProtoMessage() {}
 
-func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+func (x *Subscription) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_brutus_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -187,61 +418,75 @@ func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
-func (*HistoryRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
 	return file_proto_brutus_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *HistoryRequest) GetDevice() string {
+func (x *Subscription) GetFilters() []string {
 	if x != nil {
-		return x.Device
+		return x.Filters
 	}
-	return ""
+	return nil
 }
 
-func (x *HistoryRequest) GetParameter() string {
+func (x *Subscription) GetSnapshot() bool {
 	if x != nil {
-		return x.Parameter
+		return x.Snapshot
 	}
-	return ""
+	return false
 }
 
-func (x *HistoryRequest) GetStartTimestamp() int64 {
+func (x *Subscription) GetSinceSequence() uint64 {
 	if x != nil {
-		return x.StartTimestamp
+		return x.SinceSequence
 	}
 	return 0
 }
 
-func (x *HistoryRequest) GetEndTimestamp() int64 {
+func (x *Subscription) GetSinceTimestamp() int64 {
 	if x != nil {
-		return x.EndTimestamp
+		return x.SinceTimestamp
 	}
 	return 0
 }
 
-// Ответ с историей значений
-type HistoryResponse struct {
+func (x *Subscription) GetSlowConsumerPolicy() SlowConsumerPolicy {
+	if x != nil {
+		return x.SlowConsumerPolicy
+	}
+	return SlowConsumerPolicy_SLOW_CONSUMER_POLICY_UNSPECIFIED
+}
+
+func (x *Subscription) GetBufferSize() uint32 {
+	if x != nil {
+		return x.BufferSize
+	}
+	return 0
+}
+
+// Запрос текущих значений; фильтры те же, что в Subscription, пусто — все ряды
+type CurrentValuesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"` // список значений с временными метками
+	Filters       []string               `protobuf:"bytes,1,rep,name=filters,proto3" json:"filters,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *HistoryResponse) Reset() {
-	*x = HistoryResponse{}
+func (x *CurrentValuesRequest) Reset() {
+	*x = CurrentValuesRequest{}
 	mi := &file_proto_brutus_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *HistoryResponse) String() string {
+func (x *CurrentValuesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HistoryResponse) ProtoMessage() {}
+func (*CurrentValuesRequest) ProtoMessage() {}
 
-func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+func (x *CurrentValuesRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_brutus_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -253,43 +498,1145 @@ func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
-func (*HistoryResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use CurrentValuesRequest.ProtoReflect.Descriptor instead.
+func (*CurrentValuesRequest) Descriptor() ([]byte, []int) {
 	return file_proto_brutus_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *HistoryResponse) GetValues() []*Value {
+func (x *CurrentValuesRequest) GetFilters() []string {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+// Ответ с текущими значениями; timestamp — время последнего обновления ряда
+type CurrentValuesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CurrentValuesResponse) Reset() {
+	*x = CurrentValuesResponse{}
+	mi := &file_proto_brutus_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CurrentValuesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrentValuesResponse) ProtoMessage() {}
+
+func (x *CurrentValuesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrentValuesResponse.ProtoReflect.Descriptor instead.
+func (*CurrentValuesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CurrentValuesResponse) GetValues() []*Value {
 	if x != nil {
 		return x.Values
 	}
 	return nil
 }
 
-var File_proto_brutus_proto protoreflect.FileDescriptor
+type CommandResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Status        CommandStatus          `protobuf:"varint,2,opt,name=status,proto3,enum=brutus.CommandStatus" json:"status,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_brutus_proto_rawDesc = "" +
-	"\n" +
-	"\x12proto/brutus.proto\x12\x06brutus\"q\n" +
-	"\x05Value\x12\x16\n" +
-	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
-	"\tparameter\x18\x02 \x01(\tR\tparameter\x12\x14\n" +
-	"\x05value\x18\x03 \x01(\tR\x05value\x12\x1c\n" +
-	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\"U\n" +
-	"\aCommand\x12\x16\n" +
-	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
-	"\tparameter\x18\x02 \x01(\tR\tparameter\x12\x14\n" +
-	"\x05value\x18\x03 \x01(\tR\x05value\"\x94\x01\n" +
-	"\x0eHistoryRequest\x12\x16\n" +
-	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
-	"\tparameter\x18\x02 \x01(\tR\tparameter\x12'\n" +
-	"\x0fstart_timestamp\x18\x03 \x01(\x03R\x0estartTimestamp\x12#\n" +
-	"\rend_timestamp\x18\x04 \x01(\x03R\fendTimestamp\"8\n" +
-	"\x0fHistoryResponse\x12%\n" +
-	"\x06values\x18\x01 \x03(\v2\r.brutus.ValueR\x06values2\x85\x01\n" +
-	"\fMQTTReceiver\x124\n" +
-	"\fDataExchange\x12\x0f.brutus.Command\x1a\r.brutus.Value\"\x00(\x010\x01\x12?\n" +
+func (x *CommandResult) Reset() {
+	*x = CommandResult{}
+	mi := &file_proto_brutus_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandResult) ProtoMessage() {}
+
+func (x *CommandResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandResult.ProtoReflect.Descriptor instead.
+func (*CommandResult) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CommandResult) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *CommandResult) GetStatus() CommandStatus {
+	if x != nil {
+		return x.Status
+	}
+	return CommandStatus_COMMAND_STATUS_UNSPECIFIED
+}
+
+func (x *CommandResult) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// Запрос истории параметров
+type HistoryRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Device         string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Parameter      string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
+	StartTimestamp int64                  `protobuf:"varint,3,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"` // Unix timestamp in milliseconds
+	EndTimestamp   int64                  `protobuf:"varint,4,opt,name=end_timestamp,json=endTimestamp,proto3" json:"end_timestamp,omitempty"`       // Unix timestamp in milliseconds
+	ValueFilter    string                 `protobuf:"bytes,5,opt,name=value_filter,json=valueFilter,proto3" json:"value_filter,omitempty"`           // фильтр по числовому значению: "value > 30", "> 10 and <= 20"
+	Limit          int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`                                         // размер страницы; 0 — вся выборка. В StreamHistory — предел числа строк
+	PageToken      string                 `protobuf:"bytes,7,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`                 // next_page_token предыдущей страницы
+	Descending     bool                   `protobuf:"varint,8,opt,name=descending,proto3" json:"descending,omitempty"`                               // от новых значений к старым
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_proto_brutus_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryRequest) ProtoMessage() {}
+
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HistoryRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetParameter() string {
+	if x != nil {
+		return x.Parameter
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetStartTimestamp() int64 {
+	if x != nil {
+		return x.StartTimestamp
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetEndTimestamp() int64 {
+	if x != nil {
+		return x.EndTimestamp
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetValueFilter() string {
+	if x != nil {
+		return x.ValueFilter
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+// Ответ с историей значений
+type HistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`                                      // список значений с временными метками
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // пусто — страниц больше нет
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryResponse) Reset() {
+	*x = HistoryResponse{}
+	mi := &file_proto_brutus_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryResponse) ProtoMessage() {}
+
+func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HistoryResponse) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *HistoryResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// Запрос истории нескольких рядов за общий период
+type MultiHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ряды "device/parameter"; допускаются MQTT-маски, как в Subscription: "wb-adc/+", "+/temperature"
+	Series         []string `protobuf:"bytes,1,rep,name=series,proto3" json:"series,omitempty"`
+	StartTimestamp int64    `protobuf:"varint,2,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"` // Unix timestamp in milliseconds
+	EndTimestamp   int64    `protobuf:"varint,3,opt,name=end_timestamp,json=endTimestamp,proto3" json:"end_timestamp,omitempty"`       // Unix timestamp in milliseconds
+	// Шаг выравнивания в миллисекундах: значения сводятся в корзины с общими для всех рядов
+	// границами, timestamp значения — начало корзины. 0 — исходные значения без выравнивания
+	AlignMs        int64     `protobuf:"varint,4,opt,name=align_ms,json=alignMs,proto3" json:"align_ms,omitempty"`
+	Aggregate      Aggregate `protobuf:"varint,5,opt,name=aggregate,proto3,enum=brutus.Aggregate" json:"aggregate,omitempty"`             // агрегат корзины при выравнивании; по умолчанию avg
+	LimitPerSeries int32     `protobuf:"varint,6,opt,name=limit_per_series,json=limitPerSeries,proto3" json:"limit_per_series,omitempty"` // без выравнивания: не больше строк на ряд (до 10000), 0 — 10000
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *MultiHistoryRequest) Reset() {
+	*x = MultiHistoryRequest{}
+	mi := &file_proto_brutus_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MultiHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MultiHistoryRequest) ProtoMessage() {}
+
+func (x *MultiHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MultiHistoryRequest.ProtoReflect.Descriptor instead.
+func (*MultiHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MultiHistoryRequest) GetSeries() []string {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+func (x *MultiHistoryRequest) GetStartTimestamp() int64 {
+	if x != nil {
+		return x.StartTimestamp
+	}
+	return 0
+}
+
+func (x *MultiHistoryRequest) GetEndTimestamp() int64 {
+	if x != nil {
+		return x.EndTimestamp
+	}
+	return 0
+}
+
+func (x *MultiHistoryRequest) GetAlignMs() int64 {
+	if x != nil {
+		return x.AlignMs
+	}
+	return 0
+}
+
+func (x *MultiHistoryRequest) GetAggregate() Aggregate {
+	if x != nil {
+		return x.Aggregate
+	}
+	return Aggregate_AGGREGATE_UNSPECIFIED
+}
+
+func (x *MultiHistoryRequest) GetLimitPerSeries() int32 {
+	if x != nil {
+		return x.LimitPerSeries
+	}
+	return 0
+}
+
+// История одного ряда
+type SeriesHistory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Parameter     string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
+	Values        []*Value               `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SeriesHistory) Reset() {
+	*x = SeriesHistory{}
+	mi := &file_proto_brutus_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SeriesHistory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeriesHistory) ProtoMessage() {}
+
+func (x *SeriesHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeriesHistory.ProtoReflect.Descriptor instead.
+func (*SeriesHistory) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SeriesHistory) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *SeriesHistory) GetParameter() string {
+	if x != nil {
+		return x.Parameter
+	}
+	return ""
+}
+
+func (x *SeriesHistory) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// Ответ с историей рядов, упорядоченных по device/parameter
+type MultiHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Series        []*SeriesHistory       `protobuf:"bytes,1,rep,name=series,proto3" json:"series,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MultiHistoryResponse) Reset() {
+	*x = MultiHistoryResponse{}
+	mi := &file_proto_brutus_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MultiHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MultiHistoryResponse) ProtoMessage() {}
+
+func (x *MultiHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MultiHistoryResponse.ProtoReflect.Descriptor instead.
+func (*MultiHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MultiHistoryResponse) GetSeries() []*SeriesHistory {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+// Запрос прореженной истории
+type AggregatedHistoryRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Device         string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Parameter      string                 `protobuf:"bytes,2,opt,name=parameter,proto3" json:"parameter,omitempty"`
+	StartTimestamp int64                  `protobuf:"varint,3,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"` // Unix timestamp in milliseconds
+	EndTimestamp   int64                  `protobuf:"varint,4,opt,name=end_timestamp,json=endTimestamp,proto3" json:"end_timestamp,omitempty"`       // Unix timestamp in milliseconds
+	BucketMs       int64                  `protobuf:"varint,5,opt,name=bucket_ms,json=bucketMs,proto3" json:"bucket_ms,omitempty"`                   // размер корзины в миллисекундах; начало периода выравнивается по границе корзины
+	Aggregates     []Aggregate            `protobuf:"varint,6,rep,packed,name=aggregates,proto3,enum=brutus.Aggregate" json:"aggregates,omitempty"`  // пусто — только avg
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AggregatedHistoryRequest) Reset() {
+	*x = AggregatedHistoryRequest{}
+	mi := &file_proto_brutus_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregatedHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregatedHistoryRequest) ProtoMessage() {}
+
+func (x *AggregatedHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregatedHistoryRequest.ProtoReflect.Descriptor instead.
+func (*AggregatedHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AggregatedHistoryRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *AggregatedHistoryRequest) GetParameter() string {
+	if x != nil {
+		return x.Parameter
+	}
+	return ""
+}
+
+func (x *AggregatedHistoryRequest) GetStartTimestamp() int64 {
+	if x != nil {
+		return x.StartTimestamp
+	}
+	return 0
+}
+
+func (x *AggregatedHistoryRequest) GetEndTimestamp() int64 {
+	if x != nil {
+		return x.EndTimestamp
+	}
+	return 0
+}
+
+func (x *AggregatedHistoryRequest) GetBucketMs() int64 {
+	if x != nil {
+		return x.BucketMs
+	}
+	return 0
+}
+
+func (x *AggregatedHistoryRequest) GetAggregates() []Aggregate {
+	if x != nil {
+		return x.Aggregates
+	}
+	return nil
+}
+
+// Агрегаты по одной корзине; заполнены только запрошенные поля
+type AggregatedPoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // начало корзины, Unix timestamp in milliseconds
+	Min           *float64               `protobuf:"fixed64,2,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max           *float64               `protobuf:"fixed64,3,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	Avg           *float64               `protobuf:"fixed64,4,opt,name=avg,proto3,oneof" json:"avg,omitempty"`
+	First         *float64               `protobuf:"fixed64,5,opt,name=first,proto3,oneof" json:"first,omitempty"`
+	Last          *float64               `protobuf:"fixed64,6,opt,name=last,proto3,oneof" json:"last,omitempty"`
+	Count         *int64                 `protobuf:"varint,7,opt,name=count,proto3,oneof" json:"count,omitempty"`
+	Sum           *float64               `protobuf:"fixed64,8,opt,name=sum,proto3,oneof" json:"sum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregatedPoint) Reset() {
+	*x = AggregatedPoint{}
+	mi := &file_proto_brutus_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregatedPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregatedPoint) ProtoMessage() {}
+
+func (x *AggregatedPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregatedPoint.ProtoReflect.Descriptor instead.
+func (*AggregatedPoint) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AggregatedPoint) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetAvg() float64 {
+	if x != nil && x.Avg != nil {
+		return *x.Avg
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetFirst() float64 {
+	if x != nil && x.First != nil {
+		return *x.First
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetLast() float64 {
+	if x != nil && x.Last != nil {
+		return *x.Last
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetCount() int64 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+func (x *AggregatedPoint) GetSum() float64 {
+	if x != nil && x.Sum != nil {
+		return *x.Sum
+	}
+	return 0
+}
+
+// Ответ с прореженной историей
+type AggregatedHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Points        []*AggregatedPoint     `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregatedHistoryResponse) Reset() {
+	*x = AggregatedHistoryResponse{}
+	mi := &file_proto_brutus_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregatedHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregatedHistoryResponse) ProtoMessage() {}
+
+func (x *AggregatedHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregatedHistoryResponse.ProtoReflect.Descriptor instead.
+func (*AggregatedHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AggregatedHistoryResponse) GetPoints() []*AggregatedPoint {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+// Описание контрола из meta-топиков Wiren Board
+type ControlInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"` // switch, range, value, temperature, text, ...
+	Units         string                 `protobuf:"bytes,4,opt,name=units,proto3" json:"units,omitempty"`
+	Readonly      bool                   `protobuf:"varint,5,opt,name=readonly,proto3" json:"readonly,omitempty"`
+	Order         int32                  `protobuf:"varint,6,opt,name=order,proto3" json:"order,omitempty"`
+	Min           *float64               `protobuf:"fixed64,7,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max           *float64               `protobuf:"fixed64,8,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	Error         string                 `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`                            // текущая ошибка контрола (r, w, p), пусто если ошибок нет
+	UpdatedAt     int64                  `protobuf:"varint,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix timestamp in milliseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ControlInfo) Reset() {
+	*x = ControlInfo{}
+	mi := &file_proto_brutus_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ControlInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControlInfo) ProtoMessage() {}
+
+func (x *ControlInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControlInfo.ProtoReflect.Descriptor instead.
+func (*ControlInfo) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ControlInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ControlInfo) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ControlInfo) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ControlInfo) GetUnits() string {
+	if x != nil {
+		return x.Units
+	}
+	return ""
+}
+
+func (x *ControlInfo) GetReadonly() bool {
+	if x != nil {
+		return x.Readonly
+	}
+	return false
+}
+
+func (x *ControlInfo) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+func (x *ControlInfo) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *ControlInfo) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *ControlInfo) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ControlInfo) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+// Описание устройства вместе с его контролами
+type DeviceInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Driver        string                 `protobuf:"bytes,3,opt,name=driver,proto3" json:"driver,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Controls      []*ControlInfo         `protobuf:"bytes,5,rep,name=controls,proto3" json:"controls,omitempty"`
+	UpdatedAt     int64                  `protobuf:"varint,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix timestamp in milliseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceInfo) Reset() {
+	*x = DeviceInfo{}
+	mi := &file_proto_brutus_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceInfo) ProtoMessage() {}
+
+func (x *DeviceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceInfo.ProtoReflect.Descriptor instead.
+func (*DeviceInfo) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DeviceInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetControls() []*ControlInfo {
+	if x != nil {
+		return x.Controls
+	}
+	return nil
+}
+
+func (x *DeviceInfo) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+// Запрос реестра устройств
+type DevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"` // пусто — все устройства
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DevicesRequest) Reset() {
+	*x = DevicesRequest{}
+	mi := &file_proto_brutus_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DevicesRequest) ProtoMessage() {}
+
+func (x *DevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DevicesRequest.ProtoReflect.Descriptor instead.
+func (*DevicesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DevicesRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+// Ответ с реестром устройств
+type DevicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Devices       []*DeviceInfo          `protobuf:"bytes,1,rep,name=devices,proto3" json:"devices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DevicesResponse) Reset() {
+	*x = DevicesResponse{}
+	mi := &file_proto_brutus_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DevicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DevicesResponse) ProtoMessage() {}
+
+func (x *DevicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_brutus_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DevicesResponse.ProtoReflect.Descriptor instead.
+func (*DevicesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_brutus_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DevicesResponse) GetDevices() []*DeviceInfo {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+var File_proto_brutus_proto protoreflect.FileDescriptor
+
+const file_proto_brutus_proto_rawDesc = "" +
+	"\n" +
+	"\x12proto/brutus.proto\x12\x06brutus\"\xf2\x01\n" +
+	"\x05Value\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tparameter\x18\x02 \x01(\tR\tparameter\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12-\n" +
+	"\x06result\x18\x05 \x01(\v2\x15.brutus.CommandResultR\x06result\x12\x1a\n" +
+	"\bsnapshot\x18\x06 \x01(\bR\bsnapshot\x12\x1a\n" +
+	"\bsequence\x18\a \x01(\x04R\bsequence\x12\x18\n" +
+	"\adropped\x18\b \x01(\x04R\adropped\"\xae\x01\n" +
+	"\aCommand\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tparameter\x18\x02 \x01(\tR\tparameter\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x04 \x01(\tR\trequestId\x128\n" +
+	"\fsubscription\x18\x05 \x01(\v2\x14.brutus.SubscriptionR\fsubscription\"\x83\x02\n" +
+	"\fSubscription\x12\x18\n" +
+	"\afilters\x18\x01 \x03(\tR\afilters\x12\x1a\n" +
+	"\bsnapshot\x18\x02 \x01(\bR\bsnapshot\x12%\n" +
+	"\x0esince_sequence\x18\x03 \x01(\x04R\rsinceSequence\x12'\n" +
+	"\x0fsince_timestamp\x18\x04 \x01(\x03R\x0esinceTimestamp\x12L\n" +
+	"\x14slow_consumer_policy\x18\x05 \x01(\x0e2\x1a.brutus.SlowConsumerPolicyR\x12slowConsumerPolicy\x12\x1f\n" +
+	"\vbuffer_size\x18\x06 \x01(\rR\n" +
+	"bufferSize\"0\n" +
+	"\x14CurrentValuesRequest\x12\x18\n" +
+	"\afilters\x18\x01 \x03(\tR\afilters\">\n" +
+	"\x15CurrentValuesResponse\x12%\n" +
+	"\x06values\x18\x01 \x03(\v2\r.brutus.ValueR\x06values\"u\n" +
+	"\rCommandResult\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12-\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x15.brutus.CommandStatusR\x06status\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"\x8c\x02\n" +
+	"\x0eHistoryRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tparameter\x18\x02 \x01(\tR\tparameter\x12'\n" +
+	"\x0fstart_timestamp\x18\x03 \x01(\x03R\x0estartTimestamp\x12#\n" +
+	"\rend_timestamp\x18\x04 \x01(\x03R\fendTimestamp\x12!\n" +
+	"\fvalue_filter\x18\x05 \x01(\tR\vvalueFilter\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\a \x01(\tR\tpageToken\x12\x1e\n" +
+	"\n" +
+	"descending\x18\b \x01(\bR\n" +
+	"descending\"`\n" +
+	"\x0fHistoryResponse\x12%\n" +
+	"\x06values\x18\x01 \x03(\v2\r.brutus.ValueR\x06values\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xf1\x01\n" +
+	"\x13MultiHistoryRequest\x12\x16\n" +
+	"\x06series\x18\x01 \x03(\tR\x06series\x12'\n" +
+	"\x0fstart_timestamp\x18\x02 \x01(\x03R\x0estartTimestamp\x12#\n" +
+	"\rend_timestamp\x18\x03 \x01(\x03R\fendTimestamp\x12\x19\n" +
+	"\balign_ms\x18\x04 \x01(\x03R\aalignMs\x12/\n" +
+	"\taggregate\x18\x05 \x01(\x0e2\x11.brutus.AggregateR\taggregate\x12(\n" +
+	"\x10limit_per_series\x18\x06 \x01(\x05R\x0elimitPerSeries\"l\n" +
+	"\rSeriesHistory\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tparameter\x18\x02 \x01(\tR\tparameter\x12%\n" +
+	"\x06values\x18\x03 \x03(\v2\r.brutus.ValueR\x06values\"E\n" +
+	"\x14MultiHistoryResponse\x12-\n" +
+	"\x06series\x18\x01 \x03(\v2\x15.brutus.SeriesHistoryR\x06series\"\xee\x01\n" +
+	"\x18AggregatedHistoryRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tparameter\x18\x02 \x01(\tR\tparameter\x12'\n" +
+	"\x0fstart_timestamp\x18\x03 \x01(\x03R\x0estartTimestamp\x12#\n" +
+	"\rend_timestamp\x18\x04 \x01(\x03R\fendTimestamp\x12\x1b\n" +
+	"\tbucket_ms\x18\x05 \x01(\x03R\bbucketMs\x121\n" +
+	"\n" +
+	"aggregates\x18\x06 \x03(\x0e2\x11.brutus.AggregateR\n" +
+	"aggregates\"\x97\x02\n" +
+	"\x0fAggregatedPoint\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x15\n" +
+	"\x03min\x18\x02 \x01(\x01H\x00R\x03min\x88\x01\x01\x12\x15\n" +
+	"\x03max\x18\x03 \x01(\x01H\x01R\x03max\x88\x01\x01\x12\x15\n" +
+	"\x03avg\x18\x04 \x01(\x01H\x02R\x03avg\x88\x01\x01\x12\x19\n" +
+	"\x05first\x18\x05 \x01(\x01H\x03R\x05first\x88\x01\x01\x12\x17\n" +
+	"\x04last\x18\x06 \x01(\x01H\x04R\x04last\x88\x01\x01\x12\x19\n" +
+	"\x05count\x18\a \x01(\x03H\x05R\x05count\x88\x01\x01\x12\x15\n" +
+	"\x03sum\x18\b \x01(\x01H\x06R\x03sum\x88\x01\x01B\x06\n" +
+	"\x04_minB\x06\n" +
+	"\x04_maxB\x06\n" +
+	"\x04_avgB\b\n" +
+	"\x06_firstB\a\n" +
+	"\x05_lastB\b\n" +
+	"\x06_countB\x06\n" +
+	"\x04_sum\"L\n" +
+	"\x19AggregatedHistoryResponse\x12/\n" +
+	"\x06points\x18\x01 \x03(\v2\x17.brutus.AggregatedPointR\x06points\"\x82\x02\n" +
+	"\vControlInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x14\n" +
+	"\x05units\x18\x04 \x01(\tR\x05units\x12\x1a\n" +
+	"\breadonly\x18\x05 \x01(\bR\breadonly\x12\x14\n" +
+	"\x05order\x18\x06 \x01(\x05R\x05order\x12\x15\n" +
+	"\x03min\x18\a \x01(\x01H\x00R\x03min\x88\x01\x01\x12\x15\n" +
+	"\x03max\x18\b \x01(\x01H\x01R\x03max\x88\x01\x01\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\x03R\tupdatedAtB\x06\n" +
+	"\x04_minB\x06\n" +
+	"\x04_max\"\xb0\x01\n" +
+	"\n" +
+	"DeviceInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x16\n" +
+	"\x06driver\x18\x03 \x01(\tR\x06driver\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12/\n" +
+	"\bcontrols\x18\x05 \x03(\v2\x13.brutus.ControlInfoR\bcontrols\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\"(\n" +
+	"\x0eDevicesRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\"?\n" +
+	"\x0fDevicesResponse\x12,\n" +
+	"\adevices\x18\x01 \x03(\v2\x12.brutus.DeviceInfoR\adevices*\xce\x01\n" +
+	"\x12SlowConsumerPolicy\x12$\n" +
+	" SLOW_CONSUMER_POLICY_UNSPECIFIED\x10\x00\x12$\n" +
+	" SLOW_CONSUMER_POLICY_DROP_NEWEST\x10\x01\x12$\n" +
+	" SLOW_CONSUMER_POLICY_DROP_OLDEST\x10\x02\x12!\n" +
+	"\x1dSLOW_CONSUMER_POLICY_COALESCE\x10\x03\x12#\n" +
+	"\x1fSLOW_CONSUMER_POLICY_DISCONNECT\x10\x04*\x86\x01\n" +
+	"\rCommandStatus\x12\x1e\n" +
+	"\x1aCOMMAND_STATUS_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16COMMAND_STATUS_APPLIED\x10\x01\x12\x1c\n" +
+	"\x18COMMAND_STATUS_TIMED_OUT\x10\x02\x12\x1b\n" +
+	"\x17COMMAND_STATUS_REJECTED\x10\x03*\xb0\x01\n" +
+	"\tAggregate\x12\x19\n" +
+	"\x15AGGREGATE_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rAGGREGATE_MIN\x10\x01\x12\x11\n" +
+	"\rAGGREGATE_MAX\x10\x02\x12\x11\n" +
+	"\rAGGREGATE_AVG\x10\x03\x12\x13\n" +
+	"\x0fAGGREGATE_FIRST\x10\x04\x12\x12\n" +
+	"\x0eAGGREGATE_LAST\x10\x05\x12\x13\n" +
+	"\x0fAGGREGATE_COUNT\x10\x06\x12\x11\n" +
+	"\rAGGREGATE_SUM\x10\a2\x8e\x04\n" +
+	"\fMQTTReceiver\x124\n" +
+	"\fDataExchange\x12\x0f.brutus.Command\x1a\r.brutus.Value\"\x00(\x010\x01\x12?\n" +
+	"\n" +
+	"GetHistory\x12\x16.brutus.HistoryRequest\x1a\x17.brutus.HistoryResponse\"\x00\x12N\n" +
+	"\x0fGetMultiHistory\x12\x1b.brutus.MultiHistoryRequest\x1a\x1c.brutus.MultiHistoryResponse\"\x00\x12D\n" +
+	"\rStreamHistory\x12\x16.brutus.HistoryRequest\x1a\x17.brutus.HistoryResponse\"\x000\x01\x12]\n" +
+	"\x14GetAggregatedHistory\x12 .brutus.AggregatedHistoryRequest\x1a!.brutus.AggregatedHistoryResponse\"\x00\x12Q\n" +
+	"\x10GetCurrentValues\x12\x1c.brutus.CurrentValuesRequest\x1a\x1d.brutus.CurrentValuesResponse\"\x00\x12?\n" +
 	"\n" +
-	"GetHistory\x12\x16.brutus.HistoryRequest\x1a\x17.brutus.HistoryResponse\"\x00B\x0eZ\fbrutus/protob\x06proto3"
+	"GetDevices\x12\x16.brutus.DevicesRequest\x1a\x17.brutus.DevicesResponse\"\x00B\x0eZ\fbrutus/protob\x06proto3"
 
 var (
 	file_proto_brutus_proto_rawDescOnce sync.Once
@@ -303,24 +1650,64 @@ func file_proto_brutus_proto_rawDescGZIP() []byte {
 	return file_proto_brutus_proto_rawDescData
 }
 
-var file_proto_brutus_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_brutus_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_proto_brutus_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_proto_brutus_proto_goTypes = []any{
-	(*Value)(nil),           // 0: brutus.Value
-	(*Command)(nil),         // 1: brutus.Command
-	(*HistoryRequest)(nil),  // 2: brutus.HistoryRequest
-	(*HistoryResponse)(nil), // 3: brutus.HistoryResponse
+	(SlowConsumerPolicy)(0),           // 0: brutus.SlowConsumerPolicy
+	(CommandStatus)(0),                // 1: brutus.CommandStatus
+	(Aggregate)(0),                    // 2: brutus.Aggregate
+	(*Value)(nil),                     // 3: brutus.Value
+	(*Command)(nil),                   // 4: brutus.Command
+	(*Subscription)(nil),              // 5: brutus.Subscription
+	(*CurrentValuesRequest)(nil),      // 6: brutus.CurrentValuesRequest
+	(*CurrentValuesResponse)(nil),     // 7: brutus.CurrentValuesResponse
+	(*CommandResult)(nil),             // 8: brutus.CommandResult
+	(*HistoryRequest)(nil),            // 9: brutus.HistoryRequest
+	(*HistoryResponse)(nil),           // 10: brutus.HistoryResponse
+	(*MultiHistoryRequest)(nil),       // 11: brutus.MultiHistoryRequest
+	(*SeriesHistory)(nil),             // 12: brutus.SeriesHistory
+	(*MultiHistoryResponse)(nil),      // 13: brutus.MultiHistoryResponse
+	(*AggregatedHistoryRequest)(nil),  // 14: brutus.AggregatedHistoryRequest
+	(*AggregatedPoint)(nil),           // 15: brutus.AggregatedPoint
+	(*AggregatedHistoryResponse)(nil), // 16: brutus.AggregatedHistoryResponse
+	(*ControlInfo)(nil),               // 17: brutus.ControlInfo
+	(*DeviceInfo)(nil),                // 18: brutus.DeviceInfo
+	(*DevicesRequest)(nil),            // 19: brutus.DevicesRequest
+	(*DevicesResponse)(nil),           // 20: brutus.DevicesResponse
 }
 var file_proto_brutus_proto_depIdxs = []int32{
-	0, // 0: brutus.HistoryResponse.values:type_name -> brutus.Value
-	1, // 1: brutus.MQTTReceiver.DataExchange:input_type -> brutus.Command
-	2, // 2: brutus.MQTTReceiver.GetHistory:input_type -> brutus.HistoryRequest
-	0, // 3: brutus.MQTTReceiver.DataExchange:output_type -> brutus.Value
-	3, // 4: brutus.MQTTReceiver.GetHistory:output_type -> brutus.HistoryResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	8,  // 0: brutus.Value.result:type_name -> brutus.CommandResult
+	5,  // 1: brutus.Command.subscription:type_name -> brutus.Subscription
+	0,  // 2: brutus.Subscription.slow_consumer_policy:type_name -> brutus.SlowConsumerPolicy
+	3,  // 3: brutus.CurrentValuesResponse.values:type_name -> brutus.Value
+	1,  // 4: brutus.CommandResult.status:type_name -> brutus.CommandStatus
+	3,  // 5: brutus.HistoryResponse.values:type_name -> brutus.Value
+	2,  // 6: brutus.MultiHistoryRequest.aggregate:type_name -> brutus.Aggregate
+	3,  // 7: brutus.SeriesHistory.values:type_name -> brutus.Value
+	12, // 8: brutus.MultiHistoryResponse.series:type_name -> brutus.SeriesHistory
+	2,  // 9: brutus.AggregatedHistoryRequest.aggregates:type_name -> brutus.Aggregate
+	15, // 10: brutus.AggregatedHistoryResponse.points:type_name -> brutus.AggregatedPoint
+	17, // 11: brutus.DeviceInfo.controls:type_name -> brutus.ControlInfo
+	18, // 12: brutus.DevicesResponse.devices:type_name -> brutus.DeviceInfo
+	4,  // 13: brutus.MQTTReceiver.DataExchange:input_type -> brutus.Command
+	9,  // 14: brutus.MQTTReceiver.GetHistory:input_type -> brutus.HistoryRequest
+	11, // 15: brutus.MQTTReceiver.GetMultiHistory:input_type -> brutus.MultiHistoryRequest
+	9,  // 16: brutus.MQTTReceiver.StreamHistory:input_type -> brutus.HistoryRequest
+	14, // 17: brutus.MQTTReceiver.GetAggregatedHistory:input_type -> brutus.AggregatedHistoryRequest
+	6,  // 18: brutus.MQTTReceiver.GetCurrentValues:input_type -> brutus.CurrentValuesRequest
+	19, // 19: brutus.MQTTReceiver.GetDevices:input_type -> brutus.DevicesRequest
+	3,  // 20: brutus.MQTTReceiver.DataExchange:output_type -> brutus.Value
+	10, // 21: brutus.MQTTReceiver.GetHistory:output_type -> brutus.HistoryResponse
+	13, // 22: brutus.MQTTReceiver.GetMultiHistory:output_type -> brutus.MultiHistoryResponse
+	10, // 23: brutus.MQTTReceiver.StreamHistory:output_type -> brutus.HistoryResponse
+	16, // 24: brutus.MQTTReceiver.GetAggregatedHistory:output_type -> brutus.AggregatedHistoryResponse
+	7,  // 25: brutus.MQTTReceiver.GetCurrentValues:output_type -> brutus.CurrentValuesResponse
+	20, // 26: brutus.MQTTReceiver.GetDevices:output_type -> brutus.DevicesResponse
+	20, // [20:27] is the sub-list for method output_type
+	13, // [13:20] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_proto_brutus_proto_init() }
@@ -328,18 +1715,21 @@ func file_proto_brutus_proto_init() {
 	if File_proto_brutus_proto != nil {
 		return
 	}
+	file_proto_brutus_proto_msgTypes[12].OneofWrappers = []any{}
+	file_proto_brutus_proto_msgTypes[14].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_brutus_proto_rawDesc), len(file_proto_brutus_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      3,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_brutus_proto_goTypes,
 		DependencyIndexes: file_proto_brutus_proto_depIdxs,
+		EnumInfos:         file_proto_brutus_proto_enumTypes,
 		MessageInfos:      file_proto_brutus_proto_msgTypes,
 	}.Build()
 	File_proto_brutus_proto = out.File
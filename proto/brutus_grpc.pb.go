@@ -21,8 +21,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MQTTReceiver_DataExchange_FullMethodName = "/brutus.MQTTReceiver/DataExchange"
-	MQTTReceiver_GetHistory_FullMethodName   = "/brutus.MQTTReceiver/GetHistory"
+	MQTTReceiver_DataExchange_FullMethodName         = "/brutus.MQTTReceiver/DataExchange"
+	MQTTReceiver_GetHistory_FullMethodName           = "/brutus.MQTTReceiver/GetHistory"
+	MQTTReceiver_GetMultiHistory_FullMethodName      = "/brutus.MQTTReceiver/GetMultiHistory"
+	MQTTReceiver_StreamHistory_FullMethodName        = "/brutus.MQTTReceiver/StreamHistory"
+	MQTTReceiver_GetAggregatedHistory_FullMethodName = "/brutus.MQTTReceiver/GetAggregatedHistory"
+	MQTTReceiver_GetCurrentValues_FullMethodName     = "/brutus.MQTTReceiver/GetCurrentValues"
+	MQTTReceiver_GetDevices_FullMethodName           = "/brutus.MQTTReceiver/GetDevices"
 )
 
 // MQTTReceiverClient is the client API for MQTTReceiver service.
@@ -33,6 +38,16 @@ type MQTTReceiverClient interface {
 	DataExchange(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Command, Value], error)
 	// Получение истории значений параметра
 	GetHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	// Получение истории нескольких рядов одним запросом
+	GetMultiHistory(ctx context.Context, in *MultiHistoryRequest, opts ...grpc.CallOption) (*MultiHistoryResponse, error)
+	// Потоковая выгрузка истории частями; next_page_token последней части позволяет продолжить
+	StreamHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HistoryResponse], error)
+	// Получение истории, агрегированной по корзинам (min/max/avg/...)
+	GetAggregatedHistory(ctx context.Context, in *AggregatedHistoryRequest, opts ...grpc.CallOption) (*AggregatedHistoryResponse, error)
+	// Получение текущих значений рядов
+	GetCurrentValues(ctx context.Context, in *CurrentValuesRequest, opts ...grpc.CallOption) (*CurrentValuesResponse, error)
+	// Получение реестра устройств и контролов
+	GetDevices(ctx context.Context, in *DevicesRequest, opts ...grpc.CallOption) (*DevicesResponse, error)
 }
 
 type mQTTReceiverClient struct {
@@ -66,6 +81,65 @@ func (c *mQTTReceiverClient) GetHistory(ctx context.Context, in *HistoryRequest,
 	return out, nil
 }
 
+func (c *mQTTReceiverClient) GetMultiHistory(ctx context.Context, in *MultiHistoryRequest, opts ...grpc.CallOption) (*MultiHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MultiHistoryResponse)
+	err := c.cc.Invoke(ctx, MQTTReceiver_GetMultiHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mQTTReceiverClient) StreamHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HistoryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MQTTReceiver_ServiceDesc.Streams[1], MQTTReceiver_StreamHistory_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HistoryRequest, HistoryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MQTTReceiver_StreamHistoryClient = grpc.ServerStreamingClient[HistoryResponse]
+
+func (c *mQTTReceiverClient) GetAggregatedHistory(ctx context.Context, in *AggregatedHistoryRequest, opts ...grpc.CallOption) (*AggregatedHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AggregatedHistoryResponse)
+	err := c.cc.Invoke(ctx, MQTTReceiver_GetAggregatedHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mQTTReceiverClient) GetCurrentValues(ctx context.Context, in *CurrentValuesRequest, opts ...grpc.CallOption) (*CurrentValuesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CurrentValuesResponse)
+	err := c.cc.Invoke(ctx, MQTTReceiver_GetCurrentValues_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mQTTReceiverClient) GetDevices(ctx context.Context, in *DevicesRequest, opts ...grpc.CallOption) (*DevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DevicesResponse)
+	err := c.cc.Invoke(ctx, MQTTReceiver_GetDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MQTTReceiverServer is the server API for MQTTReceiver service.
 // All implementations must embed UnimplementedMQTTReceiverServer
 // for forward compatibility.
@@ -74,6 +148,16 @@ type MQTTReceiverServer interface {
 	DataExchange(grpc.BidiStreamingServer[Command, Value]) error
 	// Получение истории значений параметра
 	GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	// Получение истории нескольких рядов одним запросом
+	GetMultiHistory(context.Context, *MultiHistoryRequest) (*MultiHistoryResponse, error)
+	// Потоковая выгрузка истории частями; next_page_token последней части позволяет продолжить
+	StreamHistory(*HistoryRequest, grpc.ServerStreamingServer[HistoryResponse]) error
+	// Получение истории, агрегированной по корзинам (min/max/avg/...)
+	GetAggregatedHistory(context.Context, *AggregatedHistoryRequest) (*AggregatedHistoryResponse, error)
+	// Получение текущих значений рядов
+	GetCurrentValues(context.Context, *CurrentValuesRequest) (*CurrentValuesResponse, error)
+	// Получение реестра устройств и контролов
+	GetDevices(context.Context, *DevicesRequest) (*DevicesResponse, error)
 	mustEmbedUnimplementedMQTTReceiverServer()
 }
 
@@ -90,6 +174,21 @@ func (UnimplementedMQTTReceiverServer) DataExchange(grpc.BidiStreamingServer[Com
 func (UnimplementedMQTTReceiverServer) GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
 }
+func (UnimplementedMQTTReceiverServer) GetMultiHistory(context.Context, *MultiHistoryRequest) (*MultiHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMultiHistory not implemented")
+}
+func (UnimplementedMQTTReceiverServer) StreamHistory(*HistoryRequest, grpc.ServerStreamingServer[HistoryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamHistory not implemented")
+}
+func (UnimplementedMQTTReceiverServer) GetAggregatedHistory(context.Context, *AggregatedHistoryRequest) (*AggregatedHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAggregatedHistory not implemented")
+}
+func (UnimplementedMQTTReceiverServer) GetCurrentValues(context.Context, *CurrentValuesRequest) (*CurrentValuesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrentValues not implemented")
+}
+func (UnimplementedMQTTReceiverServer) GetDevices(context.Context, *DevicesRequest) (*DevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDevices not implemented")
+}
 func (UnimplementedMQTTReceiverServer) mustEmbedUnimplementedMQTTReceiverServer() {}
 func (UnimplementedMQTTReceiverServer) testEmbeddedByValue()                      {}
 
@@ -136,6 +235,89 @@ func _MQTTReceiver_GetHistory_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MQTTReceiver_GetMultiHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultiHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MQTTReceiverServer).GetMultiHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MQTTReceiver_GetMultiHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MQTTReceiverServer).GetMultiHistory(ctx, req.(*MultiHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MQTTReceiver_StreamHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MQTTReceiverServer).StreamHistory(m, &grpc.GenericServerStream[HistoryRequest, HistoryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MQTTReceiver_StreamHistoryServer = grpc.ServerStreamingServer[HistoryResponse]
+
+func _MQTTReceiver_GetAggregatedHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AggregatedHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MQTTReceiverServer).GetAggregatedHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MQTTReceiver_GetAggregatedHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MQTTReceiverServer).GetAggregatedHistory(ctx, req.(*AggregatedHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MQTTReceiver_GetCurrentValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CurrentValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MQTTReceiverServer).GetCurrentValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MQTTReceiver_GetCurrentValues_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MQTTReceiverServer).GetCurrentValues(ctx, req.(*CurrentValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MQTTReceiver_GetDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MQTTReceiverServer).GetDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MQTTReceiver_GetDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MQTTReceiverServer).GetDevices(ctx, req.(*DevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MQTTReceiver_ServiceDesc is the grpc.ServiceDesc for MQTTReceiver service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -147,6 +329,22 @@ var MQTTReceiver_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetHistory",
 			Handler:    _MQTTReceiver_GetHistory_Handler,
 		},
+		{
+			MethodName: "GetMultiHistory",
+			Handler:    _MQTTReceiver_GetMultiHistory_Handler,
+		},
+		{
+			MethodName: "GetAggregatedHistory",
+			Handler:    _MQTTReceiver_GetAggregatedHistory_Handler,
+		},
+		{
+			MethodName: "GetCurrentValues",
+			Handler:    _MQTTReceiver_GetCurrentValues_Handler,
+		},
+		{
+			MethodName: "GetDevices",
+			Handler:    _MQTTReceiver_GetDevices_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -155,6 +353,11 @@ var MQTTReceiver_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StreamHistory",
+			Handler:       _MQTTReceiver_StreamHistory_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/brutus.proto",
 }